@@ -1,22 +1,23 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/user"
 	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-	"unicode"
+	"unicode/utf8"
 
 	"bitbucket.org/puzz/anki-tts/ankitts"
-	"github.com/PuerkitoBio/goquery"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/tkrajina/anki"
 )
@@ -24,197 +25,958 @@ import (
 var (
 	config         ankitts.Config
 	params         ankitts.Params
+	flagParams     ankitts.Params
+	apiKeyResolved bool
 	askedForUpdate bool
 	update         bool
 	speechColumns  map[string]bool
+	journal        *ankitts.Journal
+	namer          *ankitts.MediaNamer
+	mediaDB        *ankitts.MediaDB
+	pipeline       *ankitts.Pipeline
+	languageMarker *regexp.Regexp
+	jsonOutput     bool
+	logOut         io.Writer = os.Stdout
+	// Pipelines of the locales of language segments
+	segmentPipelines map[string]*ankitts.Pipeline
+	// Speech fields with unexpected scripts, for the run report
+	scriptMismatches []scriptMismatch
 )
 
-func init() {
-	flag.StringVar(&params.CollectionDir, "c", "", "Collection directory")
-	flag.StringVar(&params.CardType, "t", "", "Collection type")
-	flag.StringVar(&params.DeckName, "d", "", "Deck name")
-	flag.StringVar(&params.LanguageLocale, "l", "", "Locale")
-	flag.StringVar(&params.SpeechColumnsStr, "s", "Back", "Spech columns (coma delimited)")
-
-	flag.Parse()
-	fmt.Println("Init")
+type command struct {
+	usage, description string
+	run                func(args []string)
+}
 
-	fmt.Printf("params=%#v\n", params)
+var commands = map[string]command{
+	"profiles":      {"profiles", "List Anki profiles", profilesCmd},
+	"decks":         {"decks", "List decks", decksCmd},
+	"models":        {"models", "List note types", modelsCmd},
+	"fields":        {"fields <model>", "List fields of a note type", fieldsCmd},
+	"voices":        {"voices [<locale>]", "List voices (optionally only for a locale)", voicesCmd},
+	"generate":      {"generate", "Generate audio for notes", generateCmd},
+	"run":           {"run [<job>]", "Run all jobs from the config file (or only one)", runCmd},
+	"estimate":      {"estimate [<job>]", "Count the characters a generate (or run) would send to the TTS engine", estimateCmd},
+	"verify":        {"verify", "Check that all selected notes have audio and that the audio files exist", verifyCmd},
+	"stats":         {"stats", "Count notes with and without audio", statsCmd},
+	"undo":          {"undo <run-id>", "Revert a generate run", undoCmd},
+	"clean":         {"clean", "Move audio files not used by any note to a trash folder", cleanCmd},
+	"preview-text":  {"preview-text [<text>...]", "Show how texts (or the fields of the selected notes) are prepared for the TTS engine", previewTextCmd},
+	"migrate-media": {"migrate-media", "Rename audio files named by older versions to the current naming scheme", migrateMediaCmd},
+}
 
-	if params.CollectionDir == "" || params.CardType == "" || params.DeckName == "" || params.SpeechColumnsStr == "" || params.LanguageLocale == "" {
-		flag.PrintDefaults()
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
 		os.Exit(1)
 	}
+	cmd, found := commands[os.Args[1]]
+	if !found {
+		fmt.Printf("Unknown command %s\n\n", os.Args[1])
+		printUsage()
+		os.Exit(1)
+	}
+	cmd.run(os.Args[2:])
+}
+
+func printUsage() {
+	fmt.Println("Usage: anki-tts <command> [options]")
+	fmt.Println()
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-20s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Println()
+	fmt.Println("Run anki-tts <command> -h for command options")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&flagParams.CollectionDir, "c", "", "Collection (profile) directory")
+	fs.StringVar(&flagParams.Profile, "profile", "", "Anki profile name (used if -c is not set)")
+	fs.BoolVar(&jsonOutput, "json", false, "JSON output")
+	return fs
+}
+
+func selectionFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flagParams.Query, "q", "", `Anki style search, for example 'deck:"German::Verbs" -is:suspended Back:_*'`)
+	fs.StringVar(&flagParams.CardType, "t", "", "Collection type")
+	fs.StringVar(&flagParams.DeckName, "d", "", "Deck name (* matches any text)")
+	fs.BoolVar(&flagParams.NoSubdecks, "no-subdecks", false, "Don't include subdecks of -d")
+	fs.StringVar(&flagParams.SpeechColumnsStr, "s", "", "Spech columns (coma delimited, default Back)")
+	fs.StringVar(&flagParams.CardStates, "card-states", "", "Only notes with cards in these states: new, learning, review, suspended, buried, active (default, all except suspended and buried) or all (coma delimited)")
+	fs.StringVar(&flagParams.DueWithin, "due-within", "", "Only notes with cards due within this time (for example 14d)")
+}
+
+func generateFlags(fs *flag.FlagSet) {
+	selectionFlags(fs)
+	fs.StringVar(&flagParams.LanguageLocale, "l", "", "Locale")
+	fs.StringVar(&flagParams.Voice, "voice", "", "Voice name")
+	fs.StringVar(&flagParams.Engine, "engine", "", "TTS engine (default bing)")
+	fs.IntVar(&flagParams.BudgetChars, "budget-chars", 0, "Maximum number of characters to synthesize, the most useful notes (soonest due, leeches, newest) first")
+	fs.BoolVar(&flagParams.Furigana, "furigana", false, "Speak the readings of furigana (漢字[かんじ]) and ruby markup")
+	fs.StringVar(&flagParams.ReadingField, "reading", "", "Field with the reading (for example Reading or Pinyin) to speak instead of the speech field")
+	fs.StringVar(&flagParams.OverrideField, "override", "", "Field with the exact text or SSML to speak instead of the speech field (if not empty)")
+	fs.StringVar(&flagParams.ClozeTarget, "cloze-target", "", "Fields for one clip per cloze number, {n} is the number (for example Audio{n})")
+	fs.StringVar(&flagParams.ClozeBlank, "cloze-blank", "", "Word spoken instead of the deleted text in cloze clips (default: a pause)")
+	fs.StringVar(&flagParams.LanguageMarker, "language-marker", "", "Regexp for text in another language, with the language in the first and the text in the second group")
+	fs.StringVar(&flagParams.ScriptMismatch, "script-mismatch", "", "Text in scripts the locale's voices can't read: warn (default), skip, fallback (speak it in -fallback-locale) or ignore")
+	fs.StringVar(&flagParams.FallbackLocale, "fallback-locale", "", "Locale for text in unexpected scripts with -script-mismatch fallback")
+	fs.BoolVar(&flagParams.LexiconSSML, "lexicon-ssml", false, "Write lexicon replacements as SSML <sub> instead of replacing the text")
+	fs.StringVar(&flagParams.Format, "format", "", "Audio format: mp3 (default) or wav")
+	fs.StringVar(&flagParams.Naming, "naming", "", "Media filename template (default {locale}-{slug}-{hash})")
+	fs.BoolVar(&flagParams.Transliterate, "transliterate", false, "ASCII only media filenames")
+	targetFlag(fs)
+}
+
+// targetFlag defines the -target flag of the commands which add or check the audio.
+func targetFlag(fs *flag.FlagSet) {
+	fs.StringVar(&flagParams.TargetField, "target", "", "Field for the audio (default: append audio to the speech field)")
+}
+
+// flagParamNames are the JSON names of the params set by flags.
+var flagParamNames = map[string]string{
+	"c":               "collection_dir",
+	"profile":         "profile",
+	"q":               "query",
+	"t":               "note_type",
+	"d":               "deck",
+	"no-subdecks":     "no_subdecks",
+	"s":               "fields",
+	"card-states":     "card_states",
+	"due-within":      "due_within",
+	"l":               "locale",
+	"voice":           "voice",
+	"engine":          "engine",
+	"budget-chars":    "budget_chars",
+	"furigana":        "furigana",
+	"reading":         "reading_field",
+	"override":        "override_field",
+	"cloze-target":    "cloze_target",
+	"cloze-blank":     "cloze_blank",
+	"language-marker": "language_marker",
+	"script-mismatch": "script_mismatch",
+	"fallback-locale": "fallback_locale",
+	"lexicon-ssml":    "lexicon_ssml",
+	"format":          "format",
+	"naming":          "naming",
+	"transliterate":   "transliterate",
+	"target":          "target_field",
+}
+
+// parseFlags parses the command line and sets params from the config file, environment variables and flags. Flags
+// given on the command line override even if empty (-transliterate=false).
+func parseFlags(fs *flag.FlagSet, args []string, required ...string) {
+	panicIfErrf(fs.Parse(args), "parsing flags")
+	if jsonOutput {
+		logOut = os.Stderr
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if name, found := flagParamNames[f.Name]; found {
+			flagParams.MarkSet(name)
+		}
+	})
+	loadConfig()
+	useParams(jobParams(ankitts.Params{}), fs, required...)
+}
+
+// jobParams merges the config file, the job, environment variables and flags, see ankitts.Config.Layered.
+func jobParams(job ankitts.Params) ankitts.Params {
+	env, err := ankitts.ParamsFromEnv()
+	exitIfErr(err)
+	return config.Layered(job, env, flagParams)
+}
+
+// requiredParams are the params that can be required in parseFlags, -t and -d are not needed when -q is set.
+var requiredParams = map[string]func(ankitts.Params) string{
+	"t": func(p ankitts.Params) string { return p.CardType + p.Query },
+	"d": func(p ankitts.Params) string { return p.DeckName + p.Query },
+	"s": func(p ankitts.Params) string { return p.SpeechColumnsStr },
+	"l": func(p ankitts.Params) string { return p.LanguageLocale },
+}
+
+func useParams(p ankitts.Params, fs *flag.FlagSet, required ...string) {
+	for _, name := range required {
+		if requiredParams[name](p) == "" {
+			if p.Name != "" {
+				fmt.Printf("Missing -%s%s (job %s)\n", name, missingSuffix(name), p.Name)
+			} else {
+				fmt.Printf("Missing -%s%s\n", name, missingSuffix(name))
+			}
+			fs.PrintDefaults()
+			os.Exit(1)
+		}
+	}
 
+	var err error
+	pipeline, err = newPipeline(p, p.LanguageLocale)
+	exitIfErr(err)
+	segmentPipelines = map[string]*ankitts.Pipeline{}
+	languageMarker = nil
+	if p.LanguageMarker != "" {
+		languageMarker, err = regexp.Compile(p.LanguageMarker)
+		if err != nil {
+			exitIfErr(fmt.Errorf("invalid language_marker %s: %s", p.LanguageMarker, err.Error()))
+		}
+	}
+	exitIfErr(checkScriptMismatch(p))
+
+	params = p
 	speechColumns = map[string]bool{}
 	for _, speechColumn := range strings.Split(params.SpeechColumnsStr, ",") {
 		speechColumns[strings.TrimSpace(speechColumn)] = true
 	}
+}
+
+// Loaded lexicons by filename
+var lexicons = map[string]*ankitts.Lexicon{}
+
+// newPipeline returns the text preparation pipeline of the params, followed by the lexicon for the locale (if any).
+func newPipeline(p ankitts.Params, locale string) (*ankitts.Pipeline, error) {
+	res, err := ankitts.NewPipeline(p.TextSteps(), locale)
+	if err != nil {
+		return nil, err
+	}
+	filename := config.LexiconFile(locale)
+	if filename == "" {
+		return res, nil
+	}
+	if !path.IsAbs(filename) {
+		usr, err := user.Current()
+		panicIfErrf(err, "getting user")
+		filename = path.Join(usr.HomeDir, filename)
+	}
+	lexicon, found := lexicons[filename]
+	if !found {
+		if lexicon, err = ankitts.LoadLexicon(filename); err != nil {
+			return nil, err
+		}
+		lexicons[filename] = lexicon
+	}
+	lex := *lexicon
+	lex.SSML = p.LexiconSSML
+	res.Add("lexicon", lex.Apply)
+	return res, nil
+}
+
+func missingSuffix(name string) string {
+	if name == "t" || name == "d" {
+		return " (or -q)"
+	}
+	return ""
+}
 
+func loadConfig() {
 	usr, err := user.Current()
 	panicIfErrf(err, "getting user")
 	cfgFile := path.Join(usr.HomeDir, ".anki-tts")
 
 	cfgBytes, err := ioutil.ReadFile(cfgFile)
+	if os.IsNotExist(err) {
+		return
+	}
 	panicIfErrf(err, "reading %s", cfgFile)
 
 	err = json.Unmarshal(cfgBytes, &config)
-	panicIfErrf(err, "unmarshalling %s", string(cfgBytes))
+	panicIfErrf(err, "unmarshalling %s", cfgFile)
+
+	if stat, err := os.Stat(cfgFile); err == nil && stat.Mode().Perm()&0004 != 0 && config.SpeechApiKey != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s contains the API key and is readable by all users, run chmod 600 %s or move the key to %s\n", cfgFile, cfgFile, "~/.anki-tts-secrets")
+	}
 }
 
-func main() {
-	backup()
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(logOut, format, args...)
+}
+
+// printResult prints v as JSON with -json, otherwise calls text.
+func printResult(v interface{}, text func()) {
+	if jsonOutput {
+		byts, err := json.MarshalIndent(v, "", "  ")
+		panicIfErrf(err, "marshalling result")
+		fmt.Println(string(byts))
+		return
+	}
+	text()
+}
+
+// resolveCollectionDir finds the profile directory if not set with -c.
+func resolveCollectionDir() {
+	if params.CollectionDir != "" {
+		return
+	}
+	baseDir, err := ankitts.FindAnkiBaseDir()
+	exitIfErr(err)
+	params.CollectionDir, err = ankitts.FindProfileDir(baseDir, params.Profile)
+	exitIfErr(err)
+	logf("Using collection %s\n", params.CollectionDir)
+}
+
+func openDB() (*anki.DB, *anki.Collection) {
+	resolveCollectionDir()
 
 	collectionsDb := path.Join(params.CollectionDir, "collection.anki2")
 	db, err := anki.OpenOriginalDB(collectionsDb)
 	panicIfErrf(err, "opening db %s", collectionsDb)
 
+	collection, err := db.Collection()
+	panicIfErrf(err, "getting collection")
+
+	return db, collection
+}
+
+// forEachSelectedNote calls fn once for every note of the selected deck and note type (and matching the query and card
+// filters, if set). Returns the number of notes skipped by the card filters, by reason.
+func forEachSelectedNote(db *anki.DB, collection *anki.Collection, fn func(sel ankitts.SelectedNote)) map[string]int {
+	selector := ankitts.NoteSelector{Collection: collection, ModelName: params.CardType, Now: time.Now()}
+	cardFilter, err := ankitts.NewCardFilter(params.CardStates, params.DueWithin)
+	exitIfErr(err)
+	if params.Query != "" {
+		selector.Query, err = ankitts.ParseQuery(params.Query)
+		exitIfErr(err)
+	}
+	if params.DeckName != "" {
+		selector.DeckMatcher, err = ankitts.NewDeckMatcher(params.DeckName, !params.NoSubdecks)
+		exitIfErr(err)
+	}
+
+	noteIDs, err := selector.NoteIDs(db)
+	panicIfErrf(err, "selecting notes")
+	logf("%d candidate notes\n", len(noteIDs))
+
+	skipped := map[string]int{}
+	for _, noteID := range noteIDs {
+		sel, err := selector.Select(db, noteID)
+		panicIfErrf(err, "loading note %d", noteID)
+		if sel == nil {
+			continue
+		}
+		var reason string
+		if sel.Cards, reason = cardFilter.Filter(sel.Cards, selector.Now); len(sel.Cards) == 0 {
+			logf("Skipping note %d: %s\n", noteID, reason)
+			skipped[reason]++
+			continue
+		}
+		fn(*sel)
+	}
+	return skipped
+}
+
+// scriptMismatch is a speech field with text in scripts the voices of its locale can't read.
+type scriptMismatch struct {
+	NoteID anki.ID `json:"note_id"`
+	Field  string  `json:"field"`
+	ankitts.ScriptMismatch
+	// warned, skipped or spoken in the fallback locale
+	Action string `json:"action"`
+}
+
+// checkScriptMismatch validates the script_mismatch and fallback_locale params.
+func checkScriptMismatch(p ankitts.Params) error {
+	mode := p.ScriptMismatch
+	if mode == "" {
+		mode = "warn"
+	}
+	for _, m := range ankitts.ScriptMismatchModes {
+		if m == mode {
+			if mode == "fallback" && p.FallbackLocale == "" {
+				return fmt.Errorf("script_mismatch fallback needs a fallback_locale")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid script_mismatch %s, use one of: %s", mode, strings.Join(ankitts.ScriptMismatchModes, ", "))
+}
+
+type generateResult struct {
+	Job          string         `json:"job,omitempty"`
+	RunID        string         `json:"run_id,omitempty"`
+	Processed    int            `json:"processed"`
+	Skipped      map[string]int `json:"skipped"`
+	Chars        int            `json:"chars"`
+	LeftNotes    int            `json:"left_notes"`
+	LeftChars    int            `json:"left_chars"`
+	Stopped      string         `json:"stopped,omitempty"`
+	UpdatedNotes []anki.ID      `json:"updated_notes"`
+	CreatedFiles []string       `json:"created_files"`
+	// Speech fields with text in unexpected scripts
+	ScriptMismatches []scriptMismatch `json:"script_mismatches,omitempty"`
+}
+
+func generateCmd(args []string) {
+	fs := newFlagSet("generate")
+	generateFlags(fs)
+	parseFlags(fs, args, "t", "d", "s", "l")
+
+	res := generate()
+	printResult(res, func() { printGenerateResult(res) })
+}
+
+func runCmd(args []string) {
+	fs := newFlagSet("run")
+	generateFlags(fs)
+	parseFlags(fs, args)
+
+	jobs := config.Jobs
+	if fs.NArg() > 0 {
+		job, err := config.Job(fs.Arg(0))
+		exitIfErr(err)
+		jobs = []ankitts.Params{job}
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs in config file")
+		os.Exit(1)
+	}
+
+	res := []generateResult{}
+	for _, job := range jobs {
+		useParams(jobParams(job), fs, "t", "d", "s", "l")
+		logf("Job %s\n", job.Name)
+		res = append(res, generate())
+	}
+	printResult(res, func() {
+		for _, r := range res {
+			printGenerateResult(r)
+		}
+	})
+}
+
+func generate() generateResult {
+	if !apiKeyResolved {
+		key, err := ankitts.ResolveSpeechApiKey(config, ankitts.SecretServiceKeyring{})
+		exitIfErr(err)
+		config.SpeechApiKey = key
+		apiKeyResolved = true
+	}
+	resolveCollectionDir()
+	logf("params=%#v\n", params)
+
+	backup()
+
+	db, collection := openDB()
 	defer func() {
-		fmt.Println("Closing db")
+		logf("Closing db\n")
 		db.Close()
 	}()
 
-	collection, err := db.Collection()
-	panicIfErrf(err, "getting collection")
+	journal = ankitts.NewJournal(params.CollectionDir)
+	namer = ankitts.NewMediaNamer(params)
+	mediaDB = openMediaDB()
+	defer mediaDB.Close()
 
 	for modelId, model := range collection.Models {
-		fmt.Printf("model [%d] %s deck=%d\n", modelId, model.Name, model.DeckID)
+		logf("model [%d] %s deck=%d\n", modelId, model.Name, model.DeckID)
 	}
 
 	for deckId, deck := range collection.Decks {
-		fmt.Printf("deck [%d/%d] %s\n", deckId, deck.ID, deck.Name)
-	}
-
-	notesById := map[anki.ID]anki.Note{}
-	notes, err := db.Notes()
-	panicIfErrf(err, "getting notes")
-	for notes.Next() {
-		note, err := notes.Note()
-		panicIfErrf(err, "getting note")
-		notesById[note.ID] = *note
-	}
-	notes.Close()
-
-	allCards := []anki.Card{}
-
-	cards, err := db.Cards()
-	panicIfErrf(err, "getting cards")
-	for cards.Next() {
-		card, err := cards.Card()
-		panicIfErrf(err, "getting card")
-		allCards = append(allCards, *card)
-	}
-	cards.Close()
-
-	for _, card := range allCards {
-		deck, found := collection.Decks[card.DeckID]
-		//fmt.Println("found", deck.Name, deckName)
-		if found && deck.Name == params.DeckName {
-			note, found := notesById[card.NoteID]
-			if !found {
-				fmt.Printf("Note %d not found\n", card.NoteID)
-				continue
-			}
+		logf("deck [%d/%d] %s\n", deckId, deck.ID, deck.Name)
+	}
 
-			model, found := collection.Models[note.ModelID]
-			if !found {
-				fmt.Println("Model not found", note.ModelID, note.FieldValues)
-				continue
-			}
+	var processed int
+	var plans []notePlan
+	scriptMismatches = nil
+	skipped := forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+		processed++
+		if plan := planNote(sel); plan != nil {
+			plan.deck = ankitts.HomeDeckName(collection, sel.Cards[0])
+			plans = append(plans, *plan)
+		}
+	})
 
-			//fmt.Println("*", model.Name, cardType)
-			if model.Name == params.CardType {
-				process(db, note, *model)
-			} else {
-				fmt.Printf("Note %#v in deck %s but not of type %s\n", note.FieldValues, params.DeckName, params.CardType)
+	if params.BudgetChars > 0 {
+		prioritize(db, plans)
+	}
+
+	res := generateResult{
+		Job:              params.Name,
+		Processed:        processed,
+		Skipped:          skipped,
+		UpdatedNotes:     []anki.ID{},
+		ScriptMismatches: scriptMismatches,
+	}
+	ledger := loadLedger()
+	monthlyCap := config.MonthlyCaps[params.Engine]
+	for n, plan := range plans {
+		// Files of the previous plans exist now
+		planChars := chars(plan.pending(map[string]bool{}))
+		stop := ""
+		if params.BudgetChars > 0 && res.Chars+planChars > params.BudgetChars {
+			stop = fmt.Sprintf("Budget of %d characters exhausted", params.BudgetChars)
+		}
+		if monthlyCap > 0 && ledger.MonthTotal(time.Now(), params.Engine)+planChars > monthlyCap {
+			stop = fmt.Sprintf("Monthly cap of %d characters for %s reached", monthlyCap, params.Engine)
+		}
+		if stop != "" {
+			counted := map[string]bool{}
+			for _, left := range plans[n:] {
+				res.LeftNotes++
+				res.LeftChars += chars(left.pending(counted))
 			}
+			res.Stopped = stop
+			logf("%s\n", stop)
+			break
 		}
+		executePlan(db, plan)
+		res.Chars += planChars
+		panicIfErrf(ledger.Add(time.Now(), params.Engine, plan.deck, planChars), "saving usage ledger")
+	}
+
+	res.CreatedFiles = append([]string{}, journal.Files...)
+	if !journal.Empty() {
+		res.RunID = journal.RunID
+	}
+	for _, n := range journal.Notes {
+		res.UpdatedNotes = append(res.UpdatedNotes, n.NoteID)
+	}
+	return res
+}
+
+func printGenerateResult(res generateResult) {
+	if res.Job != "" {
+		fmt.Printf("Job %s\n", res.Job)
+	}
+	fmt.Printf("Processed %d notes, updated %d, created %d files, %d characters\n", res.Processed, len(res.UpdatedNotes), len(res.CreatedFiles), res.Chars)
+	if res.LeftNotes > 0 {
+		fmt.Printf("%s, left for next time: %d notes, %d characters\n", res.Stopped, res.LeftNotes, res.LeftChars)
+	}
+	for _, reason := range sortedKeys(res.Skipped) {
+		fmt.Printf("Skipped %d notes: %s\n", res.Skipped[reason], reason)
+	}
+	for _, m := range res.ScriptMismatches {
+		fmt.Printf("Script mismatch in note %d, %s: %s text for %s (%s): %s\n", m.NoteID, m.Field, strings.Join(m.Scripts, ", "), m.Locale, m.Action, m.Text)
+	}
+	if res.RunID != "" {
+		fmt.Printf("Run %s, revert with: anki-tts undo %s\n", res.RunID, res.RunID)
 	}
 }
 
+func openMediaDB() *ankitts.MediaDB {
+	m, err := ankitts.OpenMediaDB(params.CollectionDir)
+	panicIfErrf(err, "opening media database")
+	return m
+}
+
+func loadLedger() *ankitts.Ledger {
+	filename := config.LedgerFile
+	if filename == "" {
+		var err error
+		filename, err = ankitts.DefaultLedgerFile()
+		exitIfErr(err)
+	}
+	ledger, err := ankitts.LoadLedger(filename)
+	exitIfErr(err)
+	return ledger
+}
+
 func backup() {
 	backupFilename := fmt.Sprintf("%s-%s.tar", path.Base(params.CollectionDir), time.Now().Format(time.RFC3339))
 	cmd := exec.Command("tar", "-cvf", backupFilename, params.CollectionDir)
 	bytes, err := cmd.CombinedOutput()
 	panicIfErrf(err, "backup: %s", string(bytes))
-	fmt.Println(string(bytes), "\n")
+	logf("%s\n\n", string(bytes))
+
+	logf("Backup: %s\n", backupFilename)
+}
 
-	fmt.Println("Backup:", backupFilename)
+// notePlan is the work to be done for one note: the audio files to synthesize and the new field values.
+type notePlan struct {
+	note anki.Note
+	// Home deck of the first selected card, for the usage ledger
+	deck      string
+	cards     []anki.Card
+	previous  []string
+	syntheses []synthesis
 }
 
-func process(db *anki.DB, note anki.Note, model anki.Model) {
+type synthesis struct {
+	text, file string
+	// Texts in other languages, synthesized instead of text
+	segments []ankitts.Segment
+}
+
+// pending returns the syntheses of files which don't exist yet and aren't in counted, and adds their files to counted.
+// An existing file has the same audio, its name hashes the text, locale, voice, engine and format.
+func (np notePlan) pending(counted map[string]bool) []synthesis {
+	var res []synthesis
+	for _, s := range np.syntheses {
+		if _, err := os.Stat(s.file); err == nil || counted[s.file] {
+			continue
+		}
+		counted[s.file] = true
+		res = append(res, s)
+	}
+	return res
+}
+
+// chars is the number of billable characters of the syntheses.
+func chars(syntheses []synthesis) int {
+	var res int
+	for _, s := range syntheses {
+		if len(s.segments) == 0 {
+			res += utf8.RuneCountInString(s.text)
+		}
+		for _, segment := range s.segments {
+			res += utf8.RuneCountInString(segment.Text)
+		}
+	}
+	return res
+}
+
+// planNote returns nil if nothing has to be done for the note.
+func planNote(sel ankitts.SelectedNote) *notePlan {
+	note, model := sel.Note, *sel.Model
 	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	plan := &notePlan{note: note, cards: sel.Cards, previous: append([]string(nil), note.FieldValues...)}
+	note.FieldValues = append([]string(nil), note.FieldValues...)
+
+	targetField := audioField(model)
+	targetIndex := -1
+	if targetField != "" {
+		for n := range model.Fields {
+			if model.Fields[n].Name == targetField {
+				targetIndex = n
+			}
+		}
+		if targetIndex < 0 || targetIndex >= len(note.FieldValues) {
+			logf("Note %d has no field %s\n", note.ID, targetField)
+			return nil
+		}
+	}
 
+	var targetSounds []string
+	overridden := false
+	// Field index -> cloze clips
+	clipSounds := map[int][]string{}
 	for n := range model.Fields {
 		fieldName := model.Fields[n].Name
 		if _, found := speechColumns[fieldName]; found {
 			text := strings.TrimSpace(note.FieldValues[n])
 			original := text
+			// The text to speak, and the field's text without sounds (furigana brackets are kept in the field)
+			source, display := speechSource(model, note, text), ""
+			if params.Furigana || params.ReadingField != "" {
+				display = strings.TrimSpace(regexp.MustCompile(`\[sound:.*?\]`).ReplaceAllString(text, ""))
+			}
 			text = regexp.MustCompile(`\[.*?\]`).ReplaceAllString(text, "")
+			if display == "" {
+				display = text
+			}
 			if len(text) > 0 {
 				//if !strings.Contains(text, "[sound:") {
-				fmt.Printf("field %s=%s\n", fieldName, text)
+				logf("field %s=%s\n", fieldName, text)
 				//}
-				speechFile := fmt.Sprintf("%s/%s-%s.mp3", mediaDir, params.LanguageLocale, ankitts.PrepareDestfilename(text))
-				note.FieldValues[n] = text + fmt.Sprintf("[sound:%s]", path.Base(speechFile))
-
-				if original == note.FieldValues[n] {
-					fmt.Printf("unchanged %s -> %s\n", original, note.FieldValues[n])
-				} else {
-					err := ankitts.Retrieve(params, config, ankitts.Female, prepareText(text), mediaDir, speechFile)
-					panicIfErrf(err, "retrieving speech file")
-
-					fmt.Printf("changed %s -> %s\n", original, note.FieldValues[n])
-					if !askedForUpdate {
-						askedForUpdate = true
-						fmt.Println("Update? [y/n]")
-						var answer string
-						fmt.Scan(&answer)
-						update = answer == "y"
+				spoken, segments, mismatches := prepareSpeech(ankitts.RevealClozes(source))
+				// The override replaces the text of the first speech field only
+				if override := ankitts.OverrideText(fieldValue(model, note, params.OverrideField)); override != "" && !overridden {
+					logf("override %s\n", override)
+					spoken, segments, mismatches = override, nil, nil
+					overridden = true
+				}
+				for _, m := range mismatches {
+					logf("script mismatch %s: %s text for %s\n", m.Text, strings.Join(m.Scripts, ", "), m.Locale)
+					scriptMismatches = append(scriptMismatches, scriptMismatch{NoteID: note.ID, Field: fieldName, ScriptMismatch: m, Action: scriptMismatchAction()})
+				}
+				if len(mismatches) > 0 && params.ScriptMismatch == "skip" {
+					continue
+				}
+				if targetIndex < 0 && model.Type == anki.ModelTypeCloze {
+					// In the cloze field the audio would be played on the front of every card
+					if !warnedClozeModels[model.Name] {
+						warnedClozeModels[model.Name] = true
+						logf("Note type %s has no Back Extra field for the audio, set target_field to add the audio of its cloze notes\n", model.Name)
 					}
-					if update {
-						fieldsJoined := strings.Join(note.FieldValues, anki.FieldValuesDelimiter)
-						update := "update notes set flds=?, mod=?, usn=-1 where id=?"
-						updateParams := []interface{}{fieldsJoined, int(time.Now().Unix() / 1000), note.ID}
-						fmt.Printf("sql: %s with params %#v\n", update, updateParams)
-
-						res, err := db.Exec(update, updateParams...)
-						panicIfErrf(err, "updatind %d, fields %s", note.ID, fieldsJoined)
-						fmt.Println("Updated", note.ID, "to", fieldsJoined)
-
-						affected, err := res.RowsAffected()
-						panicIfErrf(err, "getting affected rows %d", note.ID)
-						if affected != 1 {
-							panic("Err updating")
-						}
+					if params.ClozeTarget != "" {
+						planClozeClips(plan, model, source, clipSounds)
 					}
+					continue
+				}
+				speechFile := path.Join(mediaDir, namer.Name(params, text, spoken))
+				if params.Transliterate && namer.TextSlug(params, spoken) == "" {
+					logf("No transliteration for %s, %s is named by the hash only\n", spoken, path.Base(speechFile))
+				}
+				sound := fmt.Sprintf("[sound:%s]", path.Base(speechFile))
+				if targetIndex < 0 {
+					note.FieldValues[n] = display + sound
+				} else {
+					original = plan.previous[targetIndex]
+					targetSounds = append(targetSounds, sound)
+				}
+
+				if strings.Contains(original, sound) {
+					logf("unchanged %s\n", original)
+				} else {
+					plan.syntheses = append(plan.syntheses, synthesis{text: spoken, file: speechFile, segments: segments})
+				}
+
+				if params.ClozeTarget != "" {
+					planClozeClips(plan, model, source, clipSounds)
 				}
 			}
 		}
 	}
+	if targetIndex >= 0 && len(targetSounds) > 0 {
+		note.FieldValues[targetIndex] = replaceSounds(note.FieldValues[targetIndex], targetSounds)
+	}
+	for index, sounds := range clipSounds {
+		note.FieldValues[index] = replaceSounds(note.FieldValues[index], sounds)
+	}
+	plan.note = note
+
+	changed := false
+	for n := range note.FieldValues {
+		if note.FieldValues[n] != plan.previous[n] {
+			changed = true
+			logf("changed %s -> %s\n", plan.previous[n], note.FieldValues[n])
+		}
+	}
+	if !changed && len(plan.syntheses) == 0 {
+		return nil
+	}
+	return plan
 }
 
-var ignoreTextForSpechRegexp = regexp.MustCompile(`\[.*?\]`)
+// prepareSpeech prepares the text with the job's pipeline, or, if it contains text in other languages (see
+// ankitts.SplitLanguages), every segment with the pipeline of its locale. The segments are nil if the whole text is
+// spoken with the job's locale and voice. Segments in scripts their locale doesn't expect are returned as mismatches,
+// and prepared for the fallback locale with script_mismatch fallback.
+func prepareSpeech(text string) (string, []ankitts.Segment, []ankitts.ScriptMismatch) {
+	var segments []ankitts.Segment
+	var mismatches []ankitts.ScriptMismatch
+	var texts []string
+	mixed := false
+	for _, segment := range ankitts.SplitLanguages(text, languageMarker) {
+		source := segment.Text
+		segment.Locale, segment.Voice = params.SegmentVoice(segment.Language)
+		segment.Text = segmentPipeline(segment.Locale).Apply(source)
+		if scripts := ankitts.UnexpectedScripts(segment.Text, segment.Locale); len(scripts) > 0 && params.ScriptMismatch != "ignore" {
+			mismatches = append(mismatches, ankitts.ScriptMismatch{Locale: segment.Locale, Scripts: scripts, Text: segment.Text})
+			if params.ScriptMismatch == "fallback" {
+				segment.Locale, segment.Voice = params.SegmentVoice(params.FallbackLocale)
+				segment.Text = segmentPipeline(segment.Locale).Apply(source)
+			}
+		}
+		if segment.Text == "" {
+			continue
+		}
+		mixed = mixed || !strings.EqualFold(segment.Locale, params.LanguageLocale) || segment.Voice != params.Voice
+		segments = append(segments, segment)
+		texts = append(texts, segment.Text)
+	}
+	if !mixed {
+		return strings.Join(texts, " "), nil, mismatches
+	}
+	return ankitts.SegmentsText(segments, params.LanguageLocale, params.Voice), segments, mismatches
+}
 
-func prepareText(str string) string {
+// scriptMismatchAction describes what is done with text in unexpected scripts, for the run report.
+func scriptMismatchAction() string {
+	switch params.ScriptMismatch {
+	case "skip":
+		return "skipped"
+	case "fallback":
+		locale, _ := params.SegmentVoice(params.FallbackLocale)
+		return "spoken in " + locale
+	}
+	return "warned"
+}
 
-	str = ignoreTextForSpechRegexp.ReplaceAllString(str, "")
+// segmentPipeline returns the text preparation pipeline for language segments in the locale.
+func segmentPipeline(locale string) *ankitts.Pipeline {
+	if strings.EqualFold(locale, params.LanguageLocale) {
+		return pipeline
+	}
+	res, found := segmentPipelines[locale]
+	if !found {
+		var err error
+		res, err = newPipeline(params, locale)
+		exitIfErr(err)
+		segmentPipelines[locale] = res
+	}
+	return res
+}
+
+// withoutLanguageMarkers replaces the inline language markers with their text.
+func withoutLanguageMarkers(text string) string {
+	if languageMarker == nil {
+		return text
+	}
+	return languageMarker.ReplaceAllString(text, "$2")
+}
+
+// Cloze note types without a field for the audio, warned about once
+var warnedClozeModels = map[string]bool{}
+
+// audioField is the field which gets the audio of the speech fields: target_field, or for cloze notes the default
+// clozeAudioField. It's empty if the audio is appended to the speech fields.
+func audioField(model anki.Model) string {
+	if params.TargetField == "" && model.Type == anki.ModelTypeCloze {
+		return clozeAudioField(model)
+	}
+	return params.TargetField
+}
+
+// clozeAudioField is the default target field for cloze notes: Back Extra (or Extra in older Anki versions), if it
+// isn't a speech field. The cloze field itself is shown on the front, where the audio would give the answer away.
+func clozeAudioField(model anki.Model) string {
+	for _, name := range []string{"Back Extra", "Extra"} {
+		for _, field := range model.Fields {
+			if field.Name == name && !speechColumns[name] {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// speechSource is the text of a speech field to prepare for synthesis: the reading (with -furigana or -reading) or the
+// field without [...].
+func speechSource(model anki.Model, note anki.Note, text string) string {
+	source := regexp.MustCompile(`\[.*?\]`).ReplaceAllString(text, "")
+	if params.Furigana {
+		source = ankitts.FuriganaReading(text)
+	}
+	if reading := fieldValue(model, note, params.ReadingField); reading != "" {
+		source = ankitts.FuriganaReading(reading)
+	}
+	return source
+}
 
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader([]byte(str)))
-	panicIfErrf(err, "removing html from %s", str)
-	str = doc.Text()
+// planClozeClips plans one clip per cloze number, with the deleted part replaced by a pause or the cloze_blank word,
+// for the fields named by cloze_target.
+func planClozeClips(plan *notePlan, model anki.Model, text string, clipSounds map[int][]string) {
+	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	gap := `<break time="1s"/>`
+	if params.ClozeBlank != "" {
+		gap = " " + pipeline.Apply(params.ClozeBlank) + " "
+	}
+	for _, ord := range ankitts.ClozeOrdinals(text) {
+		fieldName := strings.Replace(params.ClozeTarget, "{n}", strconv.Itoa(ord), -1)
+		index := -1
+		for n := range model.Fields {
+			if model.Fields[n].Name == fieldName {
+				index = n
+			}
+		}
+		if index < 0 || index >= len(plan.previous) {
+			logf("Note %d has no field %s\n", plan.note.ID, fieldName)
+			continue
+		}
 
-	res := ""
-	for _, r := range str {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			res += string(r)
-		} else if r == '.' || r == ',' || r == '!' || r == '?' {
-			res += string(r)
+		var parts []string
+		for _, part := range ankitts.ClozeClipParts(text, ord) {
+			// Clips are spoken with the job's voice only
+			parts = append(parts, strings.TrimSpace(pipeline.Apply(withoutLanguageMarkers(part))))
+		}
+		clip := strings.Join(parts, gap)
+		clipFile := path.Join(mediaDir, namer.Name(params, text, clip))
+		sound := fmt.Sprintf("[sound:%s]", path.Base(clipFile))
+		clipSounds[index] = append(clipSounds[index], sound)
+		if !strings.Contains(plan.previous[index], sound) {
+			plan.syntheses = append(plan.syntheses, synthesis{text: clip, file: clipFile})
+		}
+	}
+}
+
+// fieldValue returns the (trimmed) value of the field, or an empty string if the note has no such field.
+func fieldValue(model anki.Model, note anki.Note, fieldName string) string {
+	if fieldName == "" {
+		return ""
+	}
+	for n := range model.Fields {
+		if model.Fields[n].Name == fieldName && n < len(note.FieldValues) {
+			return strings.TrimSpace(note.FieldValues[n])
+		}
+	}
+	return ""
+}
+
+// replaceSounds replaces all sounds in the field value.
+func replaceSounds(value string, sounds []string) string {
+	value = regexp.MustCompile(`\[sound:.*?\]`).ReplaceAllString(value, "")
+	return strings.TrimSpace(value) + strings.Join(sounds, "")
+}
+
+func executePlan(db *anki.DB, plan notePlan) {
+	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	for _, s := range plan.syntheses {
+		_, statErr := os.Stat(s.file)
+		if statErr == nil {
+			// Created by an earlier run or for another note with the same text
+			logf("exists %s\n", path.Base(s.file))
+			continue
+		}
+		var err error
+		if len(s.segments) > 0 {
+			err = ankitts.RetrieveSegments(params, config, s.segments, s.file)
 		} else {
-			res += " "
+			err = ankitts.Retrieve(params, config, s.text, mediaDir, s.file)
 		}
+		panicIfErrf(err, "retrieving speech file")
+		panicIfErrf(mediaDB.Added(s.file), "registering %s", s.file)
+		panicIfErrf(journal.AddFile(s.file), "journaling %s", s.file)
+	}
+
+	note := plan.note
+	changed := false
+	for n := range note.FieldValues {
+		changed = changed || note.FieldValues[n] != plan.previous[n]
+	}
+	if !changed {
+		return
+	}
+
+	if !askedForUpdate {
+		askedForUpdate = true
+		logf("Update? [y/n]\n")
+		var answer string
+		fmt.Scan(&answer)
+		update = answer == "y"
+	}
+	if update {
+		modified := updateNote(db, note.ID, note.FieldValues)
+		panicIfErrf(journal.AddNote(note.ID, plan.previous, note.FieldValues, modified), "journaling %d", note.ID)
 	}
-	return regexp.MustCompile(`\s+`).ReplaceAllString(res, " ")
+}
+
+func updateNote(db *anki.DB, noteID anki.ID, fieldValues []string) int64 {
+	fieldsJoined := strings.Join(fieldValues, anki.FieldValuesDelimiter)
+	modified := time.Now().Unix()
+	update := "update notes set flds=?, mod=?, usn=-1 where id=?"
+	updateParams := []interface{}{fieldsJoined, modified, noteID}
+	logf("sql: %s with params %#v\n", update, updateParams)
+
+	res, err := db.Exec(update, updateParams...)
+	panicIfErrf(err, "updatind %d, fields %s", noteID, fieldsJoined)
+	logf("Updated %d to %s\n", noteID, fieldsJoined)
+
+	affected, err := res.RowsAffected()
+	panicIfErrf(err, "getting affected rows %d", noteID)
+	if affected != 1 {
+		panic("Err updating")
+	}
+	return modified
+}
+
+func sortedKeys(m map[string]int) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// exitIfErr is used for errors caused by user input, where a stack trace doesn't help.
+func exitIfErr(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
 }
 
 func panicIfErrf(err error, msgf string, args ...interface{}) {
@@ -223,3 +985,17 @@ func panicIfErrf(err error, msgf string, args ...interface{}) {
 	}
 	panic(fmt.Sprintf("%s: %s", fmt.Sprintf(msgf, args...), err.Error()))
 }
+
+// prioritize sorts the plans so that the most useful notes are processed first when the budget is limited.
+func prioritize(db *anki.DB, plans []notePlan) {
+	now := time.Now()
+	failures, err := ankitts.RecentFailures(db, now.Add(-30*24*time.Hour))
+	panicIfErrf(err, "loading reviews")
+	priorities := map[anki.ID]ankitts.Priority{}
+	for _, plan := range plans {
+		priorities[plan.note.ID] = ankitts.NotePriority(plan.note.ID, plan.cards, failures, now)
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return priorities[plans[i].note.ID].Before(priorities[plans[j].note.ID])
+	})
+}
@@ -0,0 +1,274 @@
+package ankitts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// ConcatAudio joins audio files of the same format (mp3, wav or ogg) and encoding into one file.
+func ConcatAudio(format string, parts [][]byte) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	switch format {
+	case "mp3":
+		return concatMP3(parts)
+	case "wav":
+		return concatWAV(parts)
+	case "ogg":
+		return concatOgg(parts)
+	}
+	return nil, fmt.Errorf("can't concatenate %s files", format)
+}
+
+type mp3Frame struct {
+	data       []byte
+	sampleRate int
+	mono       bool
+}
+
+var mp3Bitrates = map[[2]int][]int{
+	// {MPEG version (1 or 2, 2.5 uses the MPEG 2 tables), layer}: kbit/s for the bitrate index 1-14
+	{1, 1}: {32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{1, 2}: {32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{1, 3}: {32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{2, 1}: {32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{2, 2}: {8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	{2, 3}: {8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+var mp3SampleRates = map[int][]int{
+	10: {44100, 48000, 32000},
+	20: {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+// mp3Frames returns the complete MPEG audio frames, without ID3 tags, trailing garbage, incomplete frames or
+// Xing/Info/VBRI header frames (which would describe only this part).
+func mp3Frames(data []byte) ([]mp3Frame, error) {
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		size := int(data[6]&0x7f)<<21 | int(data[7]&0x7f)<<14 | int(data[8]&0x7f)<<7 | int(data[9]&0x7f)
+		size += 10
+		if data[5]&0x10 != 0 {
+			size += 10
+		}
+		if size > len(data) {
+			return nil, fmt.Errorf("invalid ID3 tag")
+		}
+		data = data[size:]
+	}
+
+	var res []mp3Frame
+	for len(data) >= 4 && data[0] == 0xff && data[1]&0xe0 == 0xe0 {
+		version := map[byte]int{3: 10, 2: 20, 0: 25}[(data[1]>>3)&3]
+		layer := 4 - int((data[1]>>1)&3)
+		bitrateIndex := int(data[2] >> 4)
+		sampleRateIndex := int((data[2] >> 2) & 3)
+		if version == 0 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+			break
+		}
+		tableVersion := 1
+		if version != 10 {
+			tableVersion = 2
+		}
+		bitrate := mp3Bitrates[[2]int{tableVersion, layer}][bitrateIndex-1] * 1000
+		sampleRate := mp3SampleRates[version][sampleRateIndex]
+		padding := int((data[2] >> 1) & 1)
+		mono := data[3]>>6 == 3
+
+		var length int
+		switch {
+		case layer == 1:
+			length = (12*bitrate/sampleRate + padding) * 4
+		case layer == 3 && version != 10:
+			length = 72*bitrate/sampleRate + padding
+		default:
+			length = 144*bitrate/sampleRate + padding
+		}
+		if length > len(data) {
+			break
+		}
+
+		frame := data[:length]
+		data = data[length:]
+		if len(res) == 0 && isMP3InfoFrame(frame, version, mono) {
+			continue
+		}
+		res = append(res, mp3Frame{data: frame, sampleRate: sampleRate, mono: mono})
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no MP3 frames found")
+	}
+	return res, nil
+}
+
+func isMP3InfoFrame(frame []byte, version int, mono bool) bool {
+	sideInfo := 32
+	switch {
+	case version == 10 && mono:
+		sideInfo = 17
+	case version != 10 && mono:
+		sideInfo = 9
+	case version != 10:
+		sideInfo = 17
+	}
+	for _, offset := range []int{4 + sideInfo, 4 + 32} {
+		if offset+4 <= len(frame) {
+			switch string(frame[offset : offset+4]) {
+			case "Xing", "Info", "VBRI":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func concatMP3(parts [][]byte) ([]byte, error) {
+	var res bytes.Buffer
+	var first *mp3Frame
+	for n, part := range parts {
+		frames, err := mp3Frames(part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s", n+1, err.Error())
+		}
+		if first == nil {
+			first = &frames[0]
+		}
+		for _, frame := range frames {
+			if frame.sampleRate != first.sampleRate || frame.mono != first.mono {
+				return nil, fmt.Errorf("part %d: different sample rate or channels", n+1)
+			}
+			res.Write(frame.data)
+		}
+	}
+	return res.Bytes(), nil
+}
+
+// wavChunks returns the fmt chunk and the audio data of a RIFF WAVE file.
+func wavChunks(data []byte) (fmtChunk, audio []byte, err error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, nil, fmt.Errorf("not a WAV file")
+	}
+	data = data[12:]
+	for len(data) >= 8 {
+		id := string(data[:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if id == "data" {
+			// Streamed responses can have an unknown (0 or maximum) data size
+			if size == 0 || size > len(data) {
+				size = len(data)
+			}
+			return fmtChunk, data[:size], nil
+		}
+		if size > len(data) {
+			break
+		}
+		if id == "fmt " {
+			fmtChunk = data[:size]
+		}
+		if size+size%2 > len(data) {
+			break
+		}
+		data = data[size+size%2:]
+	}
+	return nil, nil, fmt.Errorf("invalid WAV file")
+}
+
+func concatWAV(parts [][]byte) ([]byte, error) {
+	var fmtChunk []byte
+	var audio bytes.Buffer
+	for n, part := range parts {
+		f, a, err := wavChunks(part)
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s", n+1, err.Error())
+		}
+		if f == nil {
+			return nil, fmt.Errorf("part %d: no fmt chunk", n+1)
+		}
+		if fmtChunk == nil {
+			fmtChunk = f
+		} else if !bytes.Equal(fmtChunk, f) {
+			return nil, fmt.Errorf("part %d: different audio format", n+1)
+		}
+		audio.Write(a)
+	}
+	if audio.Len()%2 == 1 {
+		audio.WriteByte(0)
+	}
+
+	var res bytes.Buffer
+	le := binary.LittleEndian
+	res.WriteString("RIFF")
+	binary.Write(&res, le, uint32(4+8+len(fmtChunk)+8+audio.Len()))
+	res.WriteString("WAVE")
+	res.WriteString("fmt ")
+	binary.Write(&res, le, uint32(len(fmtChunk)))
+	res.Write(fmtChunk)
+	res.WriteString("data")
+	binary.Write(&res, le, uint32(audio.Len()))
+	res.Write(audio.Bytes())
+	return res.Bytes(), nil
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for n := range table {
+		crc := uint32(n) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[n] = crc
+	}
+	return table
+}()
+
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// concatOgg chains the Ogg streams, every part gets its own serial number as required for chained streams.
+func concatOgg(parts [][]byte) ([]byte, error) {
+	var res bytes.Buffer
+	var serial uint32
+	for n, part := range parts {
+		data := append([]byte(nil), part...)
+		for len(data) > 0 {
+			if len(data) < 27 || string(data[:4]) != "OggS" {
+				return nil, fmt.Errorf("part %d: invalid Ogg page", n+1)
+			}
+			segments := int(data[26])
+			if len(data) < 27+segments {
+				return nil, fmt.Errorf("part %d: truncated Ogg page", n+1)
+			}
+			size := 27 + segments
+			for _, s := range data[27 : 27+segments] {
+				size += int(s)
+			}
+			if size > len(data) {
+				return nil, fmt.Errorf("part %d: truncated Ogg page", n+1)
+			}
+			page := data[:size]
+			data = data[size:]
+
+			if n == 0 {
+				serial = binary.LittleEndian.Uint32(page[14:18])
+			} else {
+				binary.LittleEndian.PutUint32(page[14:18], serial+uint32(n))
+				binary.LittleEndian.PutUint32(page[22:26], 0)
+				binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+			}
+			res.Write(page)
+		}
+	}
+	return res.Bytes(), nil
+}
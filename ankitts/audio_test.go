@@ -0,0 +1,153 @@
+package ankitts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// testMP3Frame returns an MPEG 1 layer 3 frame of 128 kbit/s (417 bytes at 44.1 kHz, 384 at 48 kHz) filled with b.
+func testMP3Frame(sampleRate int, mono bool, b byte) []byte {
+	header := []byte{0xff, 0xfb, 0x90, 0x00}
+	length := 417
+	if sampleRate == 48000 {
+		header[2] |= 1 << 2
+		length = 384
+	}
+	if mono {
+		header[3] = 0xc0
+	}
+	return append(header, bytes.Repeat([]byte{b}, length-4)...)
+}
+
+func testMP3InfoFrame() []byte {
+	frame := testMP3Frame(44100, false, 0)
+	copy(frame[4+32:], "Info")
+	return frame
+}
+
+func joinParts(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func testWAV(fmtChunk []byte, audio []byte) []byte {
+	var res bytes.Buffer
+	le := binary.LittleEndian
+	res.WriteString("RIFF")
+	binary.Write(&res, le, uint32(4+8+len(fmtChunk)+8+len(audio)))
+	res.WriteString("WAVE")
+	res.WriteString("fmt ")
+	binary.Write(&res, le, uint32(len(fmtChunk)))
+	res.Write(fmtChunk)
+	res.WriteString("data")
+	binary.Write(&res, le, uint32(len(audio)))
+	res.Write(audio)
+	return res.Bytes()
+}
+
+func testOggPage(serial uint32, data []byte) []byte {
+	page := make([]byte, 27)
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint32(page[14:18], serial)
+	page[26] = 1
+	page = append(page, byte(len(data)))
+	page = append(page, data...)
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+	return page
+}
+
+func TestConcatMP3(t *testing.T) {
+	id3 := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x05"), "tags!"...)
+	a, b, c := testMP3Frame(44100, false, 1), testMP3Frame(44100, false, 2), testMP3Frame(44100, false, 3)
+	res, err := ConcatAudio("mp3", [][]byte{
+		joinParts(id3, testMP3InfoFrame(), a, b),
+		joinParts(c, []byte("garbage"), c[:100]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res, joinParts(a, b, c)) {
+		t.Errorf("expected the three audio frames, got %d bytes", len(res))
+	}
+
+	for _, test := range []struct {
+		name  string
+		parts [][]byte
+		err   string
+	}{
+		{name: "sample rate", parts: [][]byte{a, testMP3Frame(48000, false, 1)}, err: "part 2: different sample rate"},
+		{name: "channels", parts: [][]byte{a, testMP3Frame(44100, true, 1)}, err: "part 2: different sample rate or channels"},
+		{name: "no frames", parts: [][]byte{a, []byte("not audio")}, err: "part 2: no MP3 frames"},
+		{name: "truncated ID3", parts: [][]byte{[]byte("ID3\x04\x00\x00\x00\x00\x01\x00"), a}, err: "part 1: invalid ID3 tag"},
+	} {
+		if _, err := ConcatAudio("mp3", test.parts); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestConcatWAV(t *testing.T) {
+	fmtChunk := []byte("0123456789abcdef")
+	res, err := ConcatAudio("wav", [][]byte{testWAV(fmtChunk, []byte("abc")), testWAV(fmtChunk, []byte("de"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd audio data is padded
+	if expected := testWAV(fmtChunk, []byte("abcde\x00")); !bytes.Equal(res, expected) {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+
+	// Streamed parts with an unknown data size
+	streamed := testWAV(fmtChunk, []byte("xy"))
+	binary.LittleEndian.PutUint32(streamed[len(streamed)-6:], 0)
+	if res, err = ConcatAudio("wav", [][]byte{testWAV(fmtChunk, []byte("ab")), streamed}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := testWAV(fmtChunk, []byte("abxy")); !bytes.Equal(res, expected) {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+
+	for _, test := range []struct {
+		name  string
+		parts [][]byte
+		err   string
+	}{
+		{name: "format", parts: [][]byte{testWAV(fmtChunk, []byte("ab")), testWAV([]byte("fedcba9876543210"), []byte("cd"))}, err: "part 2: different audio format"},
+		{name: "not WAV", parts: [][]byte{testWAV(fmtChunk, []byte("ab")), []byte("RIFF....AVI ")}, err: "part 2: not a WAV file"},
+	} {
+		if _, err := ConcatAudio("wav", test.parts); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestConcatOgg(t *testing.T) {
+	first := joinParts(testOggPage(7, []byte("one")), testOggPage(7, []byte("two")))
+	second := testOggPage(7, []byte("three"))
+	res, err := ConcatAudio("ogg", [][]byte{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second stream gets another serial number and a new checksum
+	if expected := joinParts(first, testOggPage(8, []byte("three"))); !bytes.Equal(res, expected) {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+	if !bytes.Equal(second, testOggPage(7, []byte("three"))) {
+		t.Error("the part was changed")
+	}
+
+	if _, err := ConcatAudio("ogg", [][]byte{first, second[:len(second)-1]}); err == nil || !strings.Contains(err.Error(), "part 2: truncated Ogg page") {
+		t.Errorf("expected a truncated page error, got %v", err)
+	}
+}
+
+func TestConcatAudio(t *testing.T) {
+	// One part is returned as it is, even if the format isn't supported
+	if res, err := ConcatAudio("flac", [][]byte{[]byte("audio")}); err != nil || string(res) != "audio" {
+		t.Errorf("expected the part, got %q, %v", res, err)
+	}
+	if _, err := ConcatAudio("flac", [][]byte{[]byte("a"), []byte("b")}); err == nil {
+		t.Error("expected an error for flac")
+	}
+}
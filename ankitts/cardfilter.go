@@ -0,0 +1,137 @@
+package ankitts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/anki"
+)
+
+const (
+	CardStateNew       = "new"
+	CardStateLearning  = "learning"
+	CardStateReview    = "review"
+	CardStateSuspended = "suspended"
+	CardStateBuried    = "buried"
+)
+
+var cardStateGroups = map[string][]string{
+	"all":    {CardStateNew, CardStateLearning, CardStateReview, CardStateSuspended, CardStateBuried},
+	"active": {CardStateNew, CardStateLearning, CardStateReview},
+}
+
+func CardState(card anki.Card) string {
+	switch {
+	case card.Queue == anki.CardQueueSuspended:
+		return CardStateSuspended
+	case card.Queue == anki.CardQueueBuried || card.Queue == anki.CardQueueSchedBuried:
+		return CardStateBuried
+	case card.Queue == anki.CardQueueLearning || card.Queue == anki.CardQueueRelearning || card.Type == anki.CardTypeLearning:
+		return CardStateLearning
+	case card.Type == anki.CardTypeReview:
+		return CardStateReview
+	default:
+		return CardStateNew
+	}
+}
+
+// CardFilter filters cards by state and due date, a note is processed if at least one of its cards passes.
+type CardFilter struct {
+	States map[string]bool
+	// Zero for no limit. Cards without a due date (new cards) don't pass if set.
+	DueWithin time.Duration
+}
+
+// NewCardFilter parses a comma delimited list of states (new, learning, review, suspended, buried, or the groups all
+// and active) and a duration like 14d, 2w or 36h.
+func NewCardFilter(states, dueWithin string) (*CardFilter, error) {
+	cf := &CardFilter{States: map[string]bool{}}
+	if strings.TrimSpace(states) == "" {
+		states = "active"
+	}
+	for _, state := range strings.Split(states, ",") {
+		state = strings.ToLower(strings.TrimSpace(state))
+		if group, found := cardStateGroups[state]; found {
+			for _, s := range group {
+				cf.States[s] = true
+			}
+			continue
+		}
+		if !contains(cardStateGroups["all"], state) {
+			return nil, fmt.Errorf("invalid card state %s", state)
+		}
+		cf.States[state] = true
+	}
+
+	if dueWithin != "" {
+		d, err := ParseDays(dueWithin)
+		if err != nil {
+			return nil, err
+		}
+		cf.DueWithin = d
+	}
+	return cf, nil
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDays parses durations with day (d) and week (w) units, other values are parsed with time.ParseDuration.
+func ParseDays(str string) (time.Duration, error) {
+	str = strings.TrimSpace(str)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(str, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(str, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %s", str)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(str)
+}
+
+// Reason returns why the card doesn't pass the filter, or an empty string if it passes.
+func (cf CardFilter) Reason(card anki.Card, now time.Time) string {
+	if state := CardState(card); !cf.States[state] {
+		return state
+	}
+	if cf.DueWithin > 0 {
+		if card.Due == nil || time.Time(*card.Due).After(now.Add(cf.DueWithin)) {
+			return "not due within " + FormatDays(cf.DueWithin)
+		}
+	}
+	return ""
+}
+
+// Filter returns the cards passing the filter, and (if none passes) the reason for the first card.
+func (cf CardFilter) Filter(cards []anki.Card, now time.Time) ([]anki.Card, string) {
+	var res []anki.Card
+	var reason string
+	for _, card := range cards {
+		if r := cf.Reason(card, now); r == "" {
+			res = append(res, card)
+		} else if reason == "" {
+			reason = r
+		}
+	}
+	if len(res) > 0 {
+		reason = ""
+	}
+	return res, reason
+}
+
+func FormatDays(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
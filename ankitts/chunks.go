@@ -0,0 +1,111 @@
+package ankitts
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EngineTextLimits is the maximum number of characters an engine accepts in one request.
+var EngineTextLimits = map[string]int{
+	"bing": 1000,
+}
+
+type textSplitter func(r rune, next rune, last bool) bool
+
+// The boundaries tried in order: sentences, clauses, words. CJK punctuation isn't followed by a space.
+var textSplitters = []textSplitter{
+	func(r, next rune, last bool) bool {
+		return strings.ContainsRune("。！？", r) || (strings.ContainsRune(".!?…", r) && (last || unicode.IsSpace(next)))
+	},
+	func(r, next rune, last bool) bool {
+		return strings.ContainsRune("，、；：", r) || (strings.ContainsRune(",;:–—", r) && (last || unicode.IsSpace(next)))
+	},
+	func(r, next rune, last bool) bool {
+		return unicode.IsSpace(r)
+	},
+}
+
+// SplitText splits text into chunks of at most limit characters, preferably at sentence boundaries, then at clause
+// boundaries, then between words. Words longer than the limit are cut.
+func SplitText(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if limit <= 0 {
+		return []string{text}
+	}
+	return splitText(text, limit, 0)
+}
+
+func splitText(text string, limit, level int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	if level >= len(textSplitters) {
+		var res []string
+		runes := []rune(text)
+		for len(runes) > limit {
+			res = append(res, string(runes[:limit]))
+			runes = runes[limit:]
+		}
+		return append(res, string(runes))
+	}
+
+	var res []string
+	var current string
+	flush := func() {
+		if current = strings.TrimSpace(current); current != "" {
+			res = append(res, splitText(current, limit, level+1)...)
+		}
+		current = ""
+	}
+	for _, piece := range splitAfter(text, textSplitters[level]) {
+		if strings.TrimSpace(current) != "" && utf8.RuneCountInString(strings.TrimSpace(current+piece)) > limit {
+			flush()
+		}
+		current += piece
+	}
+	flush()
+	return res
+}
+
+// splitAfter cuts text after every rune the splitter accepts, but not inside SSML tags or elements (<sub alias="Doktor">Dr.
+// med.</sub>), the pieces joined are the original text.
+func splitAfter(text string, splitter textSplitter) []string {
+	var res []string
+	runes := []rune(text)
+	start := 0
+	// Start of the current tag, -1 outside tags, and the number of open elements
+	tagStart, depth := -1, 0
+	for n, r := range runes {
+		if r == '<' {
+			tagStart = n
+		} else if r == '>' && tagStart >= 0 {
+			switch tag := string(runes[tagStart : n+1]); {
+			case strings.HasPrefix(tag, "</"):
+				if depth > 0 {
+					depth--
+				}
+			case !strings.HasSuffix(tag, "/>"):
+				depth++
+			}
+			tagStart = -1
+			continue
+		}
+		last := n == len(runes)-1
+		var next rune
+		if !last {
+			next = runes[n+1]
+		}
+		if tagStart < 0 && depth == 0 && splitter(r, next, last) {
+			res = append(res, string(runes[start:n+1]))
+			start = n + 1
+		}
+	}
+	if start < len(runes) {
+		res = append(res, string(runes[start:]))
+	}
+	return res
+}
@@ -0,0 +1,52 @@
+package ankitts
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitText(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		text   string
+		limit  int
+		chunks []string
+	}{
+		{name: "short", text: " Ein Satz. ", limit: 20, chunks: []string{"Ein Satz."}},
+		{name: "empty", text: "  ", limit: 20, chunks: nil},
+		{name: "no limit", text: "Eins. Zwei.", limit: 0, chunks: []string{"Eins. Zwei."}},
+		{name: "sentences", text: "Eins zwei. Drei vier. Fünf.", limit: 12, chunks: []string{"Eins zwei.", "Drei vier.", "Fünf."}},
+		{name: "sentences joined up to the limit", text: "Eins. Zwei. Drei.", limit: 11, chunks: []string{"Eins. Zwei.", "Drei."}},
+		{name: "decimal point isn't a sentence end", text: "Es kostet 3.50 Euro. Gut.", limit: 20, chunks: []string{"Es kostet 3.50 Euro.", "Gut."}},
+		{name: "clauses", text: "eins, zwei, drei, vier", limit: 11, chunks: []string{"eins, zwei,", "drei, vier"}},
+		{name: "words", text: "eins zwei drei vier", limit: 9, chunks: []string{"eins zwei", "drei vier"}},
+		{name: "long word", text: "Donaudampfschiff", limit: 6, chunks: []string{"Donaud", "ampfsc", "hiff"}},
+		{name: "CJK", text: "今日は。明日は。", limit: 5, chunks: []string{"今日は。", "明日は。"}},
+		{name: "not inside a tag", text: `<break time="1s"/> eins zwei`, limit: 20, chunks: []string{`<break time="1s"/>`, "eins zwei"}},
+		{name: "not inside an element", text: `Heute nicht. Gestern kam <sub alias="Doktor der Medizin">Dr. med.</sub> Müller.`, limit: 70,
+			chunks: []string{"Heute nicht.", `Gestern kam <sub alias="Doktor der Medizin">Dr. med.</sub> Müller.`}},
+		{name: "nested elements", text: `<voice name="a"><lang xml:lang="en">One. Two.</lang></voice> Drei.`, limit: 60,
+			chunks: []string{`<voice name="a"><lang xml:lang="en">One. Two.</lang></voice>`, "Drei."}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := SplitText(test.text, test.limit)
+			if !reflect.DeepEqual(chunks, test.chunks) {
+				t.Errorf("expected %q, got %q", test.chunks, chunks)
+			}
+		})
+	}
+}
+
+func TestSplitTextKeepsAllText(t *testing.T) {
+	text := strings.Repeat("Ein etwas längerer Satz, mit einem Komma. ", 50)
+	chunks := SplitText(text, 100)
+	for _, chunk := range chunks {
+		if n := len([]rune(chunk)); n > 100 {
+			t.Errorf("chunk of %d characters: %q", n, chunk)
+		}
+	}
+	if joined := strings.Join(chunks, " "); joined != strings.TrimSpace(text) {
+		t.Errorf("expected the text, got %q", joined)
+	}
+}
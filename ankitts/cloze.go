@@ -0,0 +1,64 @@
+package ankitts
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Innermost cloze deletion: {{c1::text}} or {{c1::text::hint}}
+var clozeRegexp = regexp.MustCompile(`\{\{c(\d+)::([^{}]*?)(?:::([^{}]*?))?\}\}`)
+
+// replaceClozes replaces the clozes from the innermost (newer Anki versions allow nested clozes).
+func replaceClozes(text string, fn func(ord int, text string) string) string {
+	for {
+		res := clozeRegexp.ReplaceAllStringFunc(text, func(cloze string) string {
+			groups := clozeRegexp.FindStringSubmatch(cloze)
+			ord, _ := strconv.Atoi(groups[1])
+			return fn(ord, groups[2])
+		})
+		if res == text {
+			return res
+		}
+		text = res
+	}
+}
+
+// RevealClozes returns the text as shown on the back of the cards, without hints.
+func RevealClozes(text string) string {
+	return replaceClozes(text, func(ord int, text string) string { return text })
+}
+
+// ClozeOrdinals returns the (sorted) cloze numbers used in the text.
+func ClozeOrdinals(text string) []int {
+	found := map[int]bool{}
+	replaceClozes(text, func(ord int, text string) string {
+		found[ord] = true
+		return text
+	})
+	var res []int
+	for ord := range found {
+		res = append(res, ord)
+	}
+	sort.Ints(res)
+	return res
+}
+
+const clozeBlank = "\x00"
+
+// ClozeClipParts returns the text of the front of card ord, split where the deleted parts are. The other clozes are
+// revealed.
+func ClozeClipParts(text string, ord int) []string {
+	res := replaceClozes(text, func(o int, text string) string {
+		if o == ord {
+			return clozeBlank
+		}
+		return text
+	})
+	// Adjacent deletions are one blank
+	for strings.Contains(res, clozeBlank+clozeBlank) {
+		res = strings.Replace(res, clozeBlank+clozeBlank, clozeBlank, -1)
+	}
+	return strings.Split(res, clozeBlank)
+}
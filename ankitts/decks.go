@@ -0,0 +1,69 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tkrajina/anki"
+)
+
+// DeckMatcher matches deck names against a glob (case insensitive, * is any text). With subdecks, "German" also
+// matches "German::Verbs".
+type DeckMatcher struct {
+	re       *regexp.Regexp
+	subdecks bool
+}
+
+func NewDeckMatcher(glob string, subdecks bool) (*DeckMatcher, error) {
+	re, err := globRegexp(glob, true)
+	if err != nil {
+		return nil, err
+	}
+	return &DeckMatcher{re: re, subdecks: subdecks}, nil
+}
+
+func (dm DeckMatcher) MatchName(name string) bool {
+	if !dm.subdecks {
+		return dm.re.MatchString(name)
+	}
+	parts := strings.Split(name, "::")
+	for n := range parts {
+		if dm.re.MatchString(strings.Join(parts[:n+1], "::")) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeckIDs returns the IDs of all matching decks.
+func (dm DeckMatcher) DeckIDs(collection *anki.Collection) map[anki.ID]bool {
+	res := map[anki.ID]bool{}
+	for id, deck := range collection.Decks {
+		if dm.MatchName(deck.Name) {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+// MatchCard matches the card's deck or, if the card is in a filtered deck, its home deck.
+func (dm DeckMatcher) MatchCard(collection *anki.Collection, card anki.Card) bool {
+	for _, deckID := range []anki.ID{card.DeckID, card.OriginalDeckID} {
+		if deck, found := collection.Decks[deckID]; found && deckID != 0 && dm.MatchName(deck.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// HomeDeckName is the name of the card's deck, or of its home deck if the card is in a filtered deck.
+func HomeDeckName(collection *anki.Collection, card anki.Card) string {
+	deckID := card.DeckID
+	if card.OriginalDeckID != 0 {
+		deckID = card.OriginalDeckID
+	}
+	if deck, found := collection.Decks[deckID]; found {
+		return deck.Name
+	}
+	return ""
+}
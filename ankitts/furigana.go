@@ -0,0 +1,39 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Anki's furigana syntax: 漢字[かんじ], the base text starts after a space or tag
+	furiganaRegexp = regexp.MustCompile(` ?([^ >\[\]]+?)\[([^\[\]]+?)\]`)
+	rubyRegexp     = regexp.MustCompile(`(?is)<ruby[^>]*>(.*?)</ruby>`)
+	rtRegexp       = regexp.MustCompile(`(?is)<rt[^>]*>(.*?)</rt>`)
+	rpRegexp       = regexp.MustCompile(`(?is)<rp[^>]*>.*?</rp>`)
+	rbTagRegexp    = regexp.MustCompile(`(?i)</?rb[^>]*>`)
+	soundTagRegexp = regexp.MustCompile(`\[sound:[^\]]*\]`)
+)
+
+// FuriganaReading replaces text with furigana (漢字[かんじ]) or ruby markup (<ruby>漢字<rt>かんじ</rt></ruby>) by its
+// reading. Sound tags are removed.
+func FuriganaReading(text string) string {
+	text = soundTagRegexp.ReplaceAllString(text, "")
+	text = rubyRegexp.ReplaceAllStringFunc(text, func(ruby string) string {
+		inner := rubyRegexp.FindStringSubmatch(ruby)[1]
+		inner = rbTagRegexp.ReplaceAllString(rpRegexp.ReplaceAllString(inner, ""), "")
+		var res strings.Builder
+		// Base text followed by its reading, base text without a reading is kept
+		for {
+			loc := rtRegexp.FindStringSubmatchIndex(inner)
+			if loc == nil {
+				res.WriteString(inner)
+				break
+			}
+			res.WriteString(inner[loc[2]:loc[3]])
+			inner = inner[loc[1]:]
+		}
+		return res.String()
+	})
+	return furiganaRegexp.ReplaceAllString(text, "$2")
+}
@@ -0,0 +1,198 @@
+package ankitts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/anki"
+)
+
+type JournalNote struct {
+	NoteID anki.ID `json:"note_id"`
+	// Field values before the run
+	Previous []string `json:"previous"`
+	// Field values and `mod` written by the run, used to detect later modifications
+	Current  []string `json:"current"`
+	Modified int64    `json:"modified"`
+}
+
+// JournalRename is a media file renamed by the run.
+type JournalRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Journal struct {
+	RunID         string          `json:"run_id"`
+	CollectionDir string          `json:"collection_dir"`
+	Notes         []JournalNote   `json:"notes"`
+	Files         []string        `json:"files"`
+	Renames       []JournalRename `json:"renames,omitempty"`
+}
+
+// NewJournal creates the journal of a run. The run ID is the time, with a suffix if a journal with this ID exists (runs
+// started in the same second, for example by anki-tts run).
+func NewJournal(collectionDir string) *Journal {
+	runID := time.Now().Format("20060102-150405")
+	for n := 2; journalExists(runID); n++ {
+		runID = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), n)
+	}
+	return &Journal{
+		RunID:         runID,
+		CollectionDir: collectionDir,
+	}
+}
+
+// journalExists is true if there is a journal (reverted or not) for the run ID.
+func journalExists(runID string) bool {
+	fn, err := journalFilename(runID)
+	if err != nil {
+		return false
+	}
+	for _, f := range []string{fn, fn + ".undone"} {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func JournalDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".anki-tts-journal"), nil
+}
+
+// journalFilename is the file of the run's journal, run IDs (given to undo) can't contain paths.
+func journalFilename(runID string) (string, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || strings.Contains(runID, "..") {
+		return "", fmt.Errorf("invalid run ID %s", runID)
+	}
+	dir, err := JournalDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, runID+".json"), nil
+}
+
+func LoadJournal(runID string) (*Journal, error) {
+	fn, err := journalFilename(runID)
+	if err != nil {
+		return nil, err
+	}
+	byts, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err := json.Unmarshal(byts, &j); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %s", fn, err.Error())
+	}
+	return &j, nil
+}
+
+// JournaledFiles returns the files created by all runs (not undone) in the collection.
+func JournaledFiles(collectionDir string) (map[string]bool, error) {
+	res := map[string]bool{}
+	dir, err := JournalDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		j, err := LoadJournal(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if j.CollectionDir != collectionDir {
+			continue
+		}
+		for _, fn := range j.Files {
+			res[fn] = true
+		}
+		for _, r := range j.Renames {
+			res[r.To] = true
+		}
+	}
+	return res, nil
+}
+
+func (j *Journal) Empty() bool {
+	return len(j.Notes) == 0 && len(j.Files) == 0 && len(j.Renames) == 0
+}
+
+// AddNote records a note update. If the note was already updated in this run only the written values are
+// replaced, the previous values stay those from before the first update.
+func (j *Journal) AddNote(noteID anki.ID, previous, current []string, modified int64) error {
+	for n := range j.Notes {
+		if j.Notes[n].NoteID == noteID {
+			j.Notes[n].Current = append([]string(nil), current...)
+			j.Notes[n].Modified = modified
+			return j.Save()
+		}
+	}
+	j.Notes = append(j.Notes, JournalNote{
+		NoteID:   noteID,
+		Previous: append([]string(nil), previous...),
+		Current:  append([]string(nil), current...),
+		Modified: modified,
+	})
+	return j.Save()
+}
+
+func (j *Journal) AddFile(filename string) error {
+	j.Files = append(j.Files, filename)
+	return j.Save()
+}
+
+// AddRename must be called before the file is renamed.
+func (j *Journal) AddRename(from, to string) error {
+	j.Renames = append(j.Renames, JournalRename{From: from, To: to})
+	return j.Save()
+}
+
+// Save is called after every change, so that the journal is usable even if the run is interrupted.
+func (j *Journal) Save() error {
+	dir, err := JournalDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	fn, err := journalFilename(j.RunID)
+	if err != nil {
+		return err
+	}
+	byts, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, byts, 0600)
+}
+
+// MarkUndone renames the journal so that the same run can't be reverted twice.
+func (j *Journal) MarkUndone() error {
+	fn, err := journalFilename(j.RunID)
+	if err != nil {
+		return err
+	}
+	return os.Rename(fn, fn+".undone")
+}
@@ -0,0 +1,89 @@
+package ankitts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LedgerEntry is the number of characters sent to an engine on one day for one deck.
+type LedgerEntry struct {
+	Day    string `json:"day"`
+	Engine string `json:"engine"`
+	Deck   string `json:"deck"`
+	Chars  int    `json:"chars"`
+}
+
+// Ledger is the persistent record of TTS usage, shared by all runs and collections.
+type Ledger struct {
+	filename string
+	Entries  []LedgerEntry `json:"entries"`
+}
+
+func DefaultLedgerFile() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".anki-tts-usage.json"), nil
+}
+
+// LoadLedger loads the ledger, a missing file is an empty ledger.
+func LoadLedger(filename string) (*Ledger, error) {
+	l := &Ledger{filename: filename}
+	byts, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(byts, l); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %s", filename, err.Error())
+	}
+	return l, nil
+}
+
+// Add records usage and saves the ledger immediately, so that usage isn't lost if the run is interrupted.
+func (l *Ledger) Add(t time.Time, engine, deck string, chars int) error {
+	day := t.Format("2006-01-02")
+	found := false
+	for n := range l.Entries {
+		e := &l.Entries[n]
+		if e.Day == day && e.Engine == engine && e.Deck == deck {
+			e.Chars += chars
+			found = true
+		}
+	}
+	if !found {
+		l.Entries = append(l.Entries, LedgerEntry{Day: day, Engine: engine, Deck: deck, Chars: chars})
+		sort.SliceStable(l.Entries, func(i, j int) bool { return l.Entries[i].Day < l.Entries[j].Day })
+	}
+	return l.Save()
+}
+
+// MonthTotal is the usage of an engine in the calendar month of t.
+func (l Ledger) MonthTotal(t time.Time, engine string) int {
+	month := t.Format("2006-01")
+	var res int
+	for _, e := range l.Entries {
+		if e.Engine == engine && strings.HasPrefix(e.Day, month) {
+			res += e.Chars
+		}
+	}
+	return res
+}
+
+func (l Ledger) Save() error {
+	byts, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.filename, byts, 0600)
+}
@@ -0,0 +1,210 @@
+package ankitts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LexiconEntry replaces a word (or phrase) with another text, or gives its IPA pronunciation.
+type LexiconEntry struct {
+	Grapheme string
+	Alias    string
+	IPA      string
+}
+
+// Lexicon is a pronunciation lexicon, loaded from a W3C PLS file or a text file with lines like:
+//
+//	# comment
+//	GmbH -> Gesellschaft mit beschränkter Haftung
+//	Tomate -> /toˈmaːtə/
+type Lexicon struct {
+	Entries []LexiconEntry
+	// SSML writes aliases as <sub alias="..."> instead of replacing the text. IPA entries are always written as
+	// <phoneme>.
+	SSML bool
+
+	regexp  *regexp.Regexp
+	entries map[string]LexiconEntry
+}
+
+// LoadLexicon loads a PLS (.pls or .xml, or starting with <) or text lexicon file.
+func LoadLexicon(filename string) (*Lexicon, error) {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var entries []LexiconEntry
+	if strings.HasSuffix(filename, ".pls") || strings.HasSuffix(filename, ".xml") || bytes.HasPrefix(bytes.TrimSpace(byts), []byte("<")) {
+		entries, err = parsePLS(byts)
+	} else {
+		entries, err = parseLexiconText(byts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading lexicon %s: %s", filename, err.Error())
+	}
+	return NewLexicon(entries), nil
+}
+
+// NewLexicon returns a lexicon with the entries, later entries for the same word win.
+func NewLexicon(entries []LexiconEntry) *Lexicon {
+	res := &Lexicon{entries: map[string]LexiconEntry{}}
+	for _, entry := range entries {
+		if _, found := res.entries[entry.Grapheme]; !found {
+			res.Entries = append(res.Entries, entry)
+		}
+		res.entries[entry.Grapheme] = entry
+	}
+	if len(res.entries) == 0 {
+		return res
+	}
+	// Longest first, so that phrases win over the words in them
+	var graphemes []string
+	for grapheme := range res.entries {
+		graphemes = append(graphemes, regexp.QuoteMeta(grapheme))
+	}
+	sort.Slice(graphemes, func(i, j int) bool {
+		if len(graphemes[i]) != len(graphemes[j]) {
+			return len(graphemes[i]) > len(graphemes[j])
+		}
+		return graphemes[i] < graphemes[j]
+	})
+	res.regexp = regexp.MustCompile(strings.Join(graphemes, "|"))
+	return res
+}
+
+func parseLexiconText(byts []byte) ([]LexiconEntry, error) {
+	var res []LexiconEntry
+	scanner := bufio.NewScanner(bytes.NewReader(byts))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "->", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected <word> -> <replacement> or <word> -> /<ipa>/", lineNo)
+		}
+		entry := LexiconEntry{Grapheme: strings.TrimSpace(parts[0])}
+		value := strings.TrimSpace(parts[1])
+		if len(value) > 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+			entry.IPA = value[1 : len(value)-1]
+		} else {
+			entry.Alias = value
+		}
+		res = append(res, entry)
+	}
+	return res, scanner.Err()
+}
+
+type plsLexicon struct {
+	Alphabet string      `xml:"alphabet,attr"`
+	Lexemes  []plsLexeme `xml:"lexeme"`
+}
+
+type plsLexeme struct {
+	Graphemes []string `xml:"grapheme"`
+	Phonemes  []struct {
+		Alphabet string `xml:"alphabet,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"phoneme"`
+	Aliases []string `xml:"alias"`
+}
+
+// parsePLS reads the lexemes of a W3C Pronunciation Lexicon, the first alias or IPA phoneme of every lexeme is used.
+func parsePLS(byts []byte) ([]LexiconEntry, error) {
+	var lexicon plsLexicon
+	if err := xml.Unmarshal(byts, &lexicon); err != nil {
+		return nil, err
+	}
+	var res []LexiconEntry
+	for _, lexeme := range lexicon.Lexemes {
+		var entry LexiconEntry
+		if len(lexeme.Aliases) > 0 {
+			entry.Alias = strings.TrimSpace(lexeme.Aliases[0])
+		} else {
+			for _, phoneme := range lexeme.Phonemes {
+				alphabet := phoneme.Alphabet
+				if alphabet == "" {
+					alphabet = lexicon.Alphabet
+				}
+				if strings.EqualFold(alphabet, "ipa") {
+					entry.IPA = strings.TrimSpace(phoneme.Value)
+					break
+				}
+			}
+		}
+		if entry.Alias == "" && entry.IPA == "" {
+			continue
+		}
+		for _, grapheme := range lexeme.Graphemes {
+			entry.Grapheme = strings.TrimSpace(grapheme)
+			res = append(res, entry)
+		}
+	}
+	return res, nil
+}
+
+// Apply replaces the whole words (or phrases) found in the lexicon.
+func (l Lexicon) Apply(text string) string {
+	if l.regexp == nil {
+		return text
+	}
+	var res strings.Builder
+	for {
+		loc := l.regexp.FindStringIndex(text)
+		if loc == nil {
+			break
+		}
+		if !wordBoundary(text, loc[0], true) || !wordBoundary(text, loc[1], false) {
+			// Part of a longer word, try again after the first character
+			_, size := utf8.DecodeRuneInString(text[loc[0]:])
+			res.WriteString(text[:loc[0]+size])
+			text = text[loc[0]+size:]
+			continue
+		}
+		res.WriteString(text[:loc[0]])
+		res.WriteString(l.replacement(l.entries[text[loc[0]:loc[1]]]))
+		text = text[loc[1]:]
+	}
+	res.WriteString(text)
+	return res.String()
+}
+
+func (l Lexicon) replacement(entry LexiconEntry) string {
+	if entry.IPA != "" {
+		return fmt.Sprintf(`<phoneme alphabet="ipa" ph="%s">%s</phoneme>`, html.EscapeString(entry.IPA), html.EscapeString(entry.Grapheme))
+	}
+	if l.SSML {
+		return fmt.Sprintf(`<sub alias="%s">%s</sub>`, html.EscapeString(entry.Alias), html.EscapeString(entry.Grapheme))
+	}
+	return entry.Alias
+}
+
+// wordBoundary checks that there is no letter or digit before (or after) the position, the grapheme itself can
+// start or end with punctuation (like "Dr.").
+func wordBoundary(text string, pos int, before bool) bool {
+	var r rune
+	if before {
+		if pos == 0 {
+			return true
+		}
+		r, _ = utf8.DecodeLastRuneInString(text[:pos])
+	} else {
+		if pos == len(text) {
+			return true
+		}
+		r, _ = utf8.DecodeRuneInString(text[pos:])
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,83 @@
+package ankitts
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/tkrajina/anki"
+)
+
+// MediaDB updates Anki's media database, so that files created or deleted outside of Anki are synced without a
+// "Check Media". Anki 2.0 uses collection.media.db, Anki 2.1 collection.media.db2, both with the same media table.
+// Missing databases are ignored.
+type MediaDB struct {
+	mediaDir string
+	dbs      []*anki.DB
+}
+
+func OpenMediaDB(collectionDir string) (*MediaDB, error) {
+	res := &MediaDB{mediaDir: path.Join(collectionDir, "collection.media")}
+	for _, name := range []string{"collection.media.db2", "collection.media.db"} {
+		fn := path.Join(collectionDir, name)
+		if _, err := os.Stat(fn); err != nil {
+			continue
+		}
+		db, err := anki.OpenOriginalDB(fn)
+		if err != nil {
+			res.Close()
+			return nil, err
+		}
+		res.dbs = append(res.dbs, db)
+	}
+	return res, nil
+}
+
+func (m *MediaDB) inMediaDir(filename string) bool {
+	return m != nil && path.Dir(filename) == path.Clean(m.mediaDir)
+}
+
+// Added registers a new or changed file in the media folder (others are ignored).
+func (m *MediaDB) Added(filename string) error {
+	if !m.inMediaDir(filename) || len(m.dbs) == 0 {
+		return nil
+	}
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum(byts)
+	return m.update(path.Base(filename), hex.EncodeToString(sum[:]), stat.ModTime().Unix())
+}
+
+// Deleted marks a file removed from the media folder (others are ignored).
+func (m *MediaDB) Deleted(filename string) error {
+	if !m.inMediaDir(filename) {
+		return nil
+	}
+	return m.update(path.Base(filename), nil, 0)
+}
+
+func (m *MediaDB) update(fname string, csum interface{}, mtime int64) error {
+	for _, db := range m.dbs {
+		if _, err := db.Exec("insert or replace into media (fname, csum, mtime, dirty) values (?, ?, ?, 1)", fname, csum, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MediaDB) Close() {
+	if m == nil {
+		return
+	}
+	for _, db := range m.dbs {
+		db.Close()
+	}
+}
@@ -0,0 +1,147 @@
+package ankitts
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultNaming is the default media filename template, see MediaNamer.
+	DefaultNaming = "{locale}-{slug}-{hash}"
+	// LegacyNaming are the names of older versions.
+	LegacyNaming = "{locale}-{legacy}"
+
+	defaultSlugLength = 40
+	// Anki truncates longer media filenames (in bytes)
+	maxFilenameBytes = 120
+	hashLength       = 12
+)
+
+var ssmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+var repeatedDashes = regexp.MustCompile(`-{2,}`)
+
+// Characters Anki doesn't allow in media filenames.
+const ankiIllegalChars = `[]<>:"/?*^\|`
+
+// MediaNamer creates media filenames from a template with the placeholders {locale}, {engine}, {voice}, {slug} (the
+// readable text, truncated), {hash} (of the spoken text, locale, voice, engine and format) and {legacy} (the full
+// text, as named by older versions).
+type MediaNamer struct {
+	Template   string
+	SlugLength int
+	// Names used in this run, to detect hash collisions
+	used map[string]string
+}
+
+func NewMediaNamer(params Params) *MediaNamer {
+	mn := &MediaNamer{Template: params.Naming, SlugLength: params.SlugLength, used: map[string]string{}}
+	if mn.Template == "" {
+		mn.Template = DefaultNaming
+	}
+	if mn.SlugLength <= 0 {
+		mn.SlugLength = defaultSlugLength
+	}
+	return mn
+}
+
+// Name returns the filename for the text sent to the engine (used for the slug and hash) and the text of the field
+// (used only for {legacy}).
+func (mn *MediaNamer) Name(params Params, text, spoken string) string {
+	format := params.AudioFormat()
+	text = norm.NFC.String(text)
+	spoken = norm.NFC.String(spoken)
+
+	key := strings.Join([]string{spoken, params.LanguageLocale, params.Voice, params.Engine, format}, "\x00")
+	sum := sha1.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+
+	slug := mn.TextSlug(params, spoken)
+	name := mn.expand(params, slug, hash, text)
+	// Shorten the slug until the name fits
+	for len(name)+1+len(format) > maxFilenameBytes && slug != "" {
+		_, size := utf8.DecodeLastRuneInString(slug)
+		slug = strings.TrimRight(slug[:len(slug)-size], "_")
+		name = mn.expand(params, slug, hash, text)
+	}
+	if len(name)+1+len(format) > maxFilenameBytes {
+		name = name[:maxFilenameBytes-1-len(format)]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+
+	base := name
+	for n := 2; mn.used[name] != "" && mn.used[name] != key; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	mn.used[name] = key
+	return name + "." + format
+}
+
+// TextSlug is the {slug} of the spoken text, it's empty if the text has no letters or digits (or none that can be
+// transliterated).
+func (mn MediaNamer) TextSlug(params Params, spoken string) string {
+	slugText := norm.NFC.String(spoken)
+	if params.Transliterate {
+		slugText = Transliterate(slugText, params.LanguageLocale)
+	}
+	return Slug(ssmlTagRegexp.ReplaceAllString(slugText, " "), mn.SlugLength)
+}
+
+func (mn MediaNamer) expand(params Params, slug, hash, text string) string {
+	res := strings.NewReplacer(
+		"{locale}", params.LanguageLocale,
+		"{engine}", params.Engine,
+		"{voice}", params.Voice,
+		"{slug}", slug,
+		"{hash}", hash,
+		"{legacy}", PrepareDestfilename(text),
+	).Replace(mn.Template)
+	// Empty placeholders, for example the slug of a text without transliterable characters
+	res = strings.Trim(repeatedDashes.ReplaceAllString(res, "-"), "-")
+	return SanitizeFilename(res)
+}
+
+// Slug is a readable version of the text: NFC normalized, only letters and digits, other characters replaced by single
+// underscores, at most maxLength characters.
+func Slug(text string, maxLength int) string {
+	var res strings.Builder
+	var count int
+	underscore := false
+	for _, r := range norm.NFC.String(text) {
+		if count >= maxLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if underscore && res.Len() > 0 {
+				res.WriteRune('_')
+				count++
+			}
+			underscore = false
+			res.WriteRune(r)
+			count++
+		} else {
+			underscore = true
+		}
+	}
+	return strings.Trim(res.String(), "_")
+}
+
+// SanitizeFilename removes characters Anki doesn't accept in media filenames, and leading and trailing dots and spaces.
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(ankiIllegalChars, r) {
+			return '_'
+		}
+		return r
+	}, norm.NFC.String(name))
+	return strings.Trim(name, ". ")
+}
@@ -0,0 +1,156 @@
+package ankitts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tkrajina/anki"
+)
+
+// SelectedNote is a note with those of its cards that matched the selection.
+type SelectedNote struct {
+	Note  anki.Note
+	Model *anki.Model
+	Cards []anki.Card
+}
+
+// NoteSelector selects notes by deck, note type and query. The deck and note type are filtered in SQL, so that only
+// the IDs of candidate notes are kept in memory.
+type NoteSelector struct {
+	Collection  *anki.Collection
+	DeckMatcher *DeckMatcher
+	// Note type name, empty for all
+	ModelName string
+	Query     Query
+	Now       time.Time
+}
+
+func idsPlaceholders(ids map[anki.ID]bool) (string, []interface{}) {
+	var placeholders []string
+	var args []interface{}
+	for id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, int64(id))
+	}
+	return strings.Join(placeholders, ","), args
+}
+
+// NoteIDs returns the IDs of notes with at least one card in the selected decks and of the selected note type.
+func (ns NoteSelector) NoteIDs(db *anki.DB) ([]anki.ID, error) {
+	query := "select distinct c.nid from cards c join notes n on n.id = c.nid where 1=1"
+	var args []interface{}
+
+	if ns.DeckMatcher != nil {
+		deckIDs := ns.DeckMatcher.DeckIDs(ns.Collection)
+		if len(deckIDs) == 0 {
+			return nil, nil
+		}
+		placeholders, deckArgs := idsPlaceholders(deckIDs)
+		query += fmt.Sprintf(" and (c.did in (%s) or c.odid in (%s))", placeholders, placeholders)
+		args = append(append(args, deckArgs...), deckArgs...)
+	}
+
+	if ns.ModelName != "" {
+		modelIDs := map[anki.ID]bool{}
+		for id, model := range ns.Collection.Models {
+			if model.Name == ns.ModelName {
+				modelIDs[id] = true
+			}
+		}
+		if len(modelIDs) == 0 {
+			return nil, nil
+		}
+		placeholders, modelArgs := idsPlaceholders(modelIDs)
+		query += fmt.Sprintf(" and n.mid in (%s)", placeholders)
+		args = append(args, modelArgs...)
+	}
+
+	query += " order by c.nid"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []anki.ID
+	for rows.Next() {
+		var id anki.ID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
+
+// Select loads the note and its cards, returns nil if none of the cards matches.
+func (ns NoteSelector) Select(db *anki.DB, noteID anki.ID) (*SelectedNote, error) {
+	note, cards, err := LoadNote(db, noteID)
+	if err != nil {
+		return nil, err
+	}
+	model, found := ns.Collection.Models[note.ModelID]
+	if !found {
+		return nil, fmt.Errorf("model %d of note %d not found", note.ModelID, noteID)
+	}
+
+	res := &SelectedNote{Note: *note, Model: model}
+	for n := range cards {
+		card := cards[n]
+		if ns.DeckMatcher != nil && !ns.DeckMatcher.MatchCard(ns.Collection, card) {
+			continue
+		}
+		if ns.Query != nil && !ns.Query.Match(QueryContext{Collection: ns.Collection, Note: note, Card: &card, Now: ns.Now}) {
+			continue
+		}
+		res.Cards = append(res.Cards, card)
+	}
+	if len(res.Cards) == 0 {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// LoadNote loads a note and its cards (with the same conversions of due and interval as in anki.DB.Cards(), and the
+// due day of day learning cards, queue 3, converted like the one of reviews).
+func LoadNote(db *anki.DB, noteID anki.ID) (*anki.Note, []anki.Card, error) {
+	var note anki.Note
+	err := db.Get(&note, `
+		SELECT n.id, n.guid, n.mid, n.mod, n.usn, n.tags, n.flds, n.sfld, CAST(n.csum AS text) AS csum
+		FROM notes n
+		WHERE n.id = ?`, noteID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading note %d: %s", noteID, err.Error())
+	}
+
+	var cards []anki.Card
+	err = db.Select(&cards, `
+		SELECT c.id, c.nid, c.did, c.ord, c.mod, c.usn, c.type, c.queue, c.reps, c.lapses, c.left, c.odid,
+			CAST(c.factor AS real)/1000 AS factor,
+			CASE c.queue
+				WHEN 0 THEN NULL
+				WHEN 1 THEN c.due
+				WHEN 2 THEN c.due*24*60*60+(SELECT crt FROM col)
+				WHEN 3 THEN c.due*24*60*60+(SELECT crt FROM col)
+			END AS due,
+			CASE
+				WHEN c.ivl == 0 THEN NULL
+				WHEN c.ivl < 0 THEN -ivl
+				ELSE c.ivl*24*60*60
+			END AS ivl,
+			CASE c.queue
+				WHEN 0 THEN NULL
+				WHEN 1 THEN c.odue
+				WHEN 2 THEN c.odue*24*60*60+(SELECT crt FROM col)
+				WHEN 3 THEN c.odue*24*60*60+(SELECT crt FROM col)
+			END AS odue
+		FROM cards c
+		WHERE c.nid = ?
+		ORDER BY c.ord`, noteID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading cards of note %d: %s", noteID, err.Error())
+	}
+	return &note, cards, nil
+}
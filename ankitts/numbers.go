@@ -0,0 +1,552 @@
+package ankitts
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type gender int
+
+const (
+	// A number on its own ("eins", "uno")
+	standalone gender = iota
+	masculine
+	feminine
+)
+
+// noun has the forms used after numbers: singular and plural, in Croatian and Russian one, few and many (see
+// slavicPlural).
+type noun struct {
+	forms    []string
+	feminine bool
+}
+
+func (n noun) gender() gender {
+	if n.feminine {
+		return feminine
+	}
+	return masculine
+}
+
+// nounGenders guesses the gender of nouns after numbers: m, f or n.
+type nounGenders struct {
+	// Lower cased nouns, the exceptions to the endings
+	words map[string]string
+	// Endings (and the last parts of compounds), the longest matching one is used
+	endings map[string]string
+}
+
+// of returns the gender of the lower cased noun, or "" if it's unknown.
+func (ng nounGenders) of(word string) string {
+	if g, found := ng.words[word]; found {
+		return g
+	}
+	for n := range word {
+		if g, found := ng.endings[word[n:]]; found {
+			return g
+		}
+	}
+	return ""
+}
+
+type currency struct {
+	main noun
+	// Cents, no sub unit if empty
+	sub noun
+}
+
+// numberLanguage spells numbers, dates, times and units in one language.
+type numberLanguage struct {
+	// Regexps of the separators
+	thousands, decimal string
+	// Words
+	minus, to string
+	// Between words, empty for Japanese
+	space    string
+	cardinal func(n int64, g gender) string
+	// The ordinal form is language specific, see ordinalForm
+	ordinal func(n int64, form string) string
+	// Number with decimals, frac are the digits after the decimal separator
+	decimalNumber func(whole int64, frac string) string
+	// Index of the noun form after the number
+	plural   func(n int64, fraction bool) int
+	fraction func(num, den int64) string
+	// The fraction after a whole number and and (two and a half), nil for fraction
+	mixedFraction func(num, den int64) string
+	// year is 0 if the date has none, before is the (lower cased) word before the date
+	date func(day, month, year int, before string) string
+	time func(hour, minute int, suffix string) string
+	// Order and separator (regexp) of numeric dates (ISO dates are always understood): dmy, mdy or ymd
+	dateOrder, dateSep string
+	// Regexps for dates with month names, with the named groups day, month and (optional) year
+	monthDates []*regexp.Regexp
+	months     []string
+	// Ordinals with the number in the first and the suffix in the second group, and an optional third group which is
+	// kept
+	ordinals *regexp.Regexp
+	// before is the (lower cased) word before the ordinal and after the noun after it
+	ordinalForm func(suffix, before, after string) string
+	// Converts a plain number with a special reading (like years after a year word), or returns false
+	special    func(n int64, before string) (string, bool)
+	units      map[string]noun
+	currencies map[string]currency
+	// Between the currency and the sub unit amount, and between a whole number and a fraction (3½)
+	and string
+	// Between a number ending with a million (or more) and the noun: un millón de euros
+	scaleOf string
+	// The gender of a plain number before the word after it (as written), nil for the standalone form. False if the
+	// word is a noun of unknown gender, numbers which depend on it are left unchanged.
+	attributive func(after string) (gender, bool)
+}
+
+// ExpandNumbers spells numbers, ordinals, fractions, dates, times, currency amounts and units in words for the
+// languages in numberLanguages (de, en, es, fr, hr, ja and ru), text in other languages is returned unchanged.
+func ExpandNumbers(text, locale string) string {
+	lang, found := numberLanguages[strings.ToLower(locale)]
+	if !found {
+		lang, found = numberLanguages[strings.ToLower(strings.SplitN(locale, "-", 2)[0])]
+	}
+	if !found {
+		return text
+	}
+	// Full width digits (Japanese)
+	text = strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, text)
+	return lang.expand(text)
+}
+
+const (
+	unitPattern     = `km/h|km²|km2|m²|m2|mph|km|cm|mm|mg|kg|ml|°C|°F|℃|%|°|min|h|m|g|l|L|км/ч|км|см|мм|мг|кг|мл|мин|ч|м|л`
+	currencyPattern = `€|\$|£|¥|₽|円|EUR|USD|GBP|JPY|RUB|HRK|kn|руб\.`
+)
+
+var (
+	isoDateRegexp    = regexp.MustCompile(`(\d{4})-(\d{1,2})-(\d{1,2})`)
+	timeRegexp       = regexp.MustCompile(`([01]?\d|2[0-3]):([0-5]\d)(?:\s?([AaPp])\.?[Mm]\.?|\s[Uu]hr)?`)
+	unicodeFractions = map[string][2]int64{"½": {1, 2}, "⅓": {1, 3}, "⅔": {2, 3}, "¼": {1, 4}, "¾": {3, 4}}
+	fractionRegexp   = regexp.MustCompile(`(?:(\d+)\s)?(\d+)/(\d+)|(\d*)([½⅓⅔¼¾])`)
+	unitAliases      = map[string]string{"km2": "km²", "m2": "m²", "℃": "°C", "L": "l", "км/ч": "km/h", "км": "km",
+		"см": "cm", "мм": "mm", "мг": "mg", "кг": "kg", "мл": "ml", "мин": "min", "ч": "h", "м": "m", "л": "l"}
+	currencyAliases = map[string]string{"EUR": "€", "USD": "$", "GBP": "£", "JPY": "¥", "円": "¥", "RUB": "₽", "руб.": "₽", "HRK": "kn"}
+)
+
+// Compiled regexps by pattern
+var numberRegexps = map[string]*regexp.Regexp{}
+
+func numberRegexp(pattern string) *regexp.Regexp {
+	re, found := numberRegexps[pattern]
+	if !found {
+		re = regexp.MustCompile(pattern)
+		numberRegexps[pattern] = re
+	}
+	return re
+}
+
+func (lang numberLanguage) expand(text string) string {
+	num := `(\d{1,3}(?:` + lang.thousands + `\d{3})+(?:` + lang.decimal + `\d+)?|\d+(?:` + lang.decimal + `\d+)?)`
+
+	// Dates
+	text = replaceMatches(isoDateRegexp, text, func(m match) (string, bool) {
+		return lang.numericDate(m.groups[3], m.groups[2], m.groups[1], m.before)
+	})
+	numericDate := numberRegexp(`(\d{1,4})` + lang.dateSep + `(\d{1,2})` + lang.dateSep + `(\d{1,4})\.?`)
+	text = replaceMatches(numericDate, text, func(m match) (string, bool) {
+		switch lang.dateOrder {
+		case "mdy":
+			return lang.numericDate(m.groups[2], m.groups[1], m.groups[3], m.before)
+		case "ymd":
+			return lang.numericDate(m.groups[3], m.groups[2], m.groups[1], m.before)
+		}
+		return lang.numericDate(m.groups[1], m.groups[2], m.groups[3], m.before)
+	})
+	for _, re := range lang.monthDates {
+		re := re
+		text = replaceMatches(re, text, func(m match) (string, bool) {
+			var day, month, year string
+			for n, name := range re.SubexpNames() {
+				switch name {
+				case "day":
+					day = m.groups[n]
+				case "month":
+					month = m.groups[n]
+				case "year":
+					year = m.groups[n]
+				}
+			}
+			for n, name := range lang.months {
+				if strings.EqualFold(name, month) {
+					return lang.numericDate(day, strconv.Itoa(n+1), year, m.before)
+				}
+			}
+			return "", false
+		})
+	}
+
+	// Times
+	text = replaceMatches(timeRegexp, text, func(m match) (string, bool) {
+		hour, _ := strconv.Atoi(m.groups[1])
+		minute, _ := strconv.Atoi(m.groups[2])
+		return lang.time(hour, minute, strings.ToUpper(m.groups[3])), true
+	})
+
+	// Ranges (5–10, 10-20 %), before the units so that the first number isn't read as negative
+	ranges := numberRegexp(num + `(\s*[–—]\s*|\s+-\s+|-)` + num)
+	text = replaceMatches(ranges, text, lang.numberRange)
+
+	// Currency amounts and units
+	currencyBefore := numberRegexp(`(` + currencyPattern + `)\s?` + num)
+	text = replaceMatches(currencyBefore, text, func(m match) (string, bool) {
+		return lang.money(m.groups[2], m.groups[1])
+	})
+	currencyAfter := numberRegexp(num + `\s?(` + currencyPattern + `)`)
+	text = replaceMatches(currencyAfter, text, func(m match) (string, bool) {
+		return lang.money(m.groups[1], m.groups[2])
+	})
+	units := numberRegexp(`([-−]?)` + num + `\s?(` + unitPattern + `)`)
+	text = replaceMatches(units, text, func(m match) (string, bool) {
+		unit := m.groups[3]
+		if alias, found := unitAliases[unit]; found {
+			unit = alias
+		}
+		n, found := lang.units[unit]
+		if !found {
+			return "", false
+		}
+		return lang.signed(m, lang.quantity(m.groups[2], n)), true
+	})
+
+	// Ordinals and fractions
+	if lang.ordinals != nil {
+		text = replaceMatches(lang.ordinals, text, func(m match) (string, bool) {
+			n, err := strconv.ParseInt(m.groups[1], 10, 64)
+			if err != nil || n == 0 {
+				return "", false
+			}
+			// The word after the ordinal (3. Platz) is kept
+			after, kept := m.after, ""
+			if len(m.groups) > 3 {
+				after, kept = strings.TrimSpace(m.groups[3]), m.groups[3]
+			}
+			return lang.ordinal(n, lang.ordinalForm(m.groups[2], m.before, after)) + kept, true
+		})
+	}
+	// Fractions with a slash only below one and with small denominators, 24/7, 50/50 and 9/11 aren't fractions
+	text = replaceMatches(fractionRegexp, text, func(m match) (string, bool) {
+		if m.groups[5] != "" {
+			f := unicodeFractions[m.groups[5]]
+			return lang.mixedNumber(m.groups[4], f[0], f[1]), true
+		}
+		num, err1 := strconv.ParseInt(m.groups[2], 10, 64)
+		den, err2 := strconv.ParseInt(m.groups[3], 10, 64)
+		if err1 != nil || err2 != nil || num < 1 || num >= den || den > 10 {
+			return "", false
+		}
+		return lang.mixedNumber(m.groups[1], num, den), true
+	})
+
+	numbers := numberRegexp(`([-−]?)` + num)
+	return replaceMatches(numbers, text, func(m match) (string, bool) {
+		g := standalone
+		if lang.attributive != nil && m.groups[1] == "" {
+			var known bool
+			if g, known = lang.attributive(m.after); !known && lang.hasGenders(m.groups[2]) {
+				return "", false
+			}
+		}
+		res, ok := lang.number(m.groups[2], g)
+		if !ok {
+			return "", false
+		}
+		if m.groups[1] != "" {
+			return lang.signed(m, res), true
+		}
+		if n, err := strconv.ParseInt(m.groups[2], 10, 64); err == nil && lang.special != nil {
+			if special, ok := lang.special(n, m.before); ok {
+				return special, true
+			}
+		}
+		return res, true
+	})
+}
+
+// Numbers in ranges with a hyphen without spaces (5-10), other numbers with hyphens are codes and phone numbers
+// (555-1234)
+var smallNumberRegexp = regexp.MustCompile(`^[1-9]\d{0,2}$`)
+
+// numberRange replaces the separator of a range (numbers in the first and last group) with the word, the numbers are
+// spelled by the later rules (5–10 km). Hyphens without spaces are only ranges of small numbers, the first lower.
+func (lang numberLanguage) numberRange(m match) (string, bool) {
+	from, to := m.groups[1], m.groups[3]
+	if m.groups[2] == "-" {
+		a, _ := strconv.Atoi(from)
+		b, _ := strconv.Atoi(to)
+		if !smallNumberRegexp.MatchString(from) || !smallNumberRegexp.MatchString(to) || a >= b {
+			return "", false
+		}
+	}
+	return from + lang.space + lang.to + lang.space + to, true
+}
+
+// signed prepends the minus to a number matched with an optional sign in the first group, unless the sign is a hyphen
+// after a word (A-4).
+func (lang numberLanguage) signed(m match, res string) string {
+	switch {
+	case m.groups[1] == "":
+		return res
+	case unicode.IsLetter(m.prev) || unicode.IsDigit(m.prev):
+		return "-" + res
+	}
+	return lang.minus + lang.space + res
+}
+
+// splitNumber returns the whole part and the digits after the decimal separator, or false if the number should be
+// read digit by digit (leading zeros like in codes and phone numbers, and very long numbers).
+func (lang numberLanguage) splitNumber(str string) (int64, string, bool) {
+	whole, frac := str, ""
+	if locs := numberRegexp(lang.decimal).FindAllStringIndex(str, -1); len(locs) > 0 {
+		last := locs[len(locs)-1]
+		whole, frac = str[:last[0]], str[last[1]:]
+	}
+	whole = numberRegexp(lang.thousands).ReplaceAllString(whole, "")
+	if (len(whole) > 1 && whole[0] == '0') || len(whole) > 15 {
+		return 0, "", false
+	}
+	n, err := strconv.ParseInt(whole, 10, 64)
+	return n, frac, err == nil
+}
+
+// number spells a number (with decimals), or returns false if it should be read digit by digit.
+func (lang numberLanguage) number(str string, g gender) (string, bool) {
+	whole, frac, ok := lang.splitNumber(str)
+	if !ok {
+		var res []string
+		for _, r := range str {
+			if unicode.IsDigit(r) {
+				res = append(res, lang.cardinal(int64(r-'0'), standalone))
+			}
+		}
+		return strings.Join(res, lang.space), true
+	}
+	if frac != "" {
+		return lang.decimalNumber(whole, frac), true
+	}
+	return lang.cardinal(whole, g), true
+}
+
+// hasGenders is true if the number is spelled differently before masculine and feminine nouns.
+func (lang numberLanguage) hasGenders(str string) bool {
+	whole, frac, ok := lang.splitNumber(str)
+	return ok && frac == "" && lang.cardinal(whole, masculine) != lang.cardinal(whole, feminine)
+}
+
+// mixedNumber spells a fraction, after the whole number and and if there is one (2 1/2, 2½).
+func (lang numberLanguage) mixedNumber(whole string, num, den int64) string {
+	if whole == "" {
+		return lang.fraction(num, den)
+	}
+	res, _ := lang.number(whole, standalone)
+	if lang.and != "" {
+		res += lang.space + lang.and
+	}
+	if lang.mixedFraction != nil {
+		return res + lang.space + lang.mixedFraction(num, den)
+	}
+	return res + lang.space + lang.fraction(num, den)
+}
+
+// quantity spells the number with the noun in the right form.
+func (lang numberLanguage) quantity(str string, n noun) string {
+	whole, frac, ok := lang.splitNumber(str)
+	spelled, _ := lang.number(str, n.gender())
+	if !ok {
+		whole = 0
+	}
+	form := lang.plural(whole, frac != "")
+	if form >= len(n.forms) {
+		form = len(n.forms) - 1
+	}
+	if lang.scaleOf != "" && whole >= 1000000 && whole%1000000 == 0 && frac == "" {
+		spelled += lang.space + lang.scaleOf
+	}
+	return spelled + lang.space + n.forms[form]
+}
+
+// money spells an amount with the currency and sub unit.
+func (lang numberLanguage) money(amount, symbol string) (string, bool) {
+	if alias, found := currencyAliases[symbol]; found {
+		symbol = alias
+	}
+	cur, found := lang.currencies[symbol]
+	if !found {
+		return "", false
+	}
+	whole, frac, ok := lang.splitNumber(amount)
+	if !ok || frac == "" || len(cur.sub.forms) == 0 || len(frac) > 2 {
+		return lang.quantity(amount, cur.main), true
+	}
+	if len(frac) == 1 {
+		frac += "0"
+	}
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+	res := lang.quantity(strconv.FormatInt(whole, 10), cur.main)
+	switch {
+	case cents == 0:
+		return res, true
+	case whole == 0:
+		// Only the cents: fifty cents
+		return lang.quantity(strconv.FormatInt(cents, 10), cur.sub), true
+	}
+	if lang.and != "" {
+		res += lang.space + lang.and
+	}
+	return res + lang.space + lang.quantity(strconv.FormatInt(cents, 10), cur.sub), true
+}
+
+func (lang numberLanguage) numericDate(dayStr, monthStr, yearStr, before string) (string, bool) {
+	day, _ := strconv.Atoi(dayStr)
+	month, _ := strconv.Atoi(monthStr)
+	year, _ := strconv.Atoi(yearStr)
+	if day < 1 || day > 31 || month < 1 || month > 12 || (yearStr != "" && (len(yearStr) != 4 || year == 0)) {
+		return "", false
+	}
+	return lang.date(day, month, year, before), true
+}
+
+type match struct {
+	groups []string
+	// The word before the match, lower cased, and the word after it as written
+	before, after string
+	// The character before the match
+	prev rune
+}
+
+// replaceMatches replaces the matches of re which aren't part of a longer word or number with the result of fn,
+// unless it returns false. Numbers joined by separators (1.2.3.4, 555-1234, 16:9) are replaced by one match or not
+// at all, a match of only some of them leaves them unchanged.
+func replaceMatches(re *regexp.Regexp, text string, fn func(m match) (string, bool)) string {
+	var res strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		first, _ := utf8.DecodeRuneInString(text[start:])
+		lastRune, _ := utf8.DecodeLastRuneInString(text[:end])
+		if (isWordRune(first) && isWordRune(prev) && start > 0) || (isWordRune(lastRune) && isWordRune(next) && end < len(text)) {
+			continue
+		}
+		if partOfNumber(text, start, end) {
+			continue
+		}
+		m := match{prev: prev, before: previousWord(text[:start]), after: nextWord(text[end:])}
+		for n := 0; n < len(loc); n += 2 {
+			if loc[n] < 0 {
+				m.groups = append(m.groups, "")
+			} else {
+				m.groups = append(m.groups, text[loc[n]:loc[n+1]])
+			}
+		}
+		replacement, ok := fn(m)
+		if !ok {
+			continue
+		}
+		res.WriteString(text[last:start])
+		res.WriteString(replacement)
+		last = end
+	}
+	res.WriteString(text[last:])
+	return res.String()
+}
+
+// Separators which join numbers without spaces
+const numberSeparators = ".,/:-"
+
+// partOfNumber checks if the match is joined to another number by a separator, or by a sign at its start, or if it
+// follows a bare decimal point.
+func partOfNumber(text string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	prev, prevSize := utf8.DecodeLastRuneInString(text[:start])
+	switch {
+	case (first == '-' || first == '−') && unicode.IsDigit(prev):
+		return true
+	case unicode.IsDigit(first) && strings.ContainsRune(numberSeparators, prev):
+		beforePrev, _ := utf8.DecodeLastRuneInString(text[:start-prevSize])
+		if unicode.IsDigit(beforePrev) {
+			return true
+		}
+		// The decimals of a number without a whole part (.5)
+		if (prev == '.' || prev == ',') && !unicode.IsLetter(beforePrev) {
+			return true
+		}
+	}
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	next, nextSize := utf8.DecodeRuneInString(text[end:])
+	if unicode.IsDigit(last) && strings.ContainsRune(numberSeparators, next) {
+		afterNext, _ := utf8.DecodeRuneInString(text[end+nextSize:])
+		return unicode.IsDigit(afterNext)
+	}
+	return false
+}
+
+// isWordRune is true for letters (except CJK, which is written without spaces) and digits.
+func isWordRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func nextWord(text string) string {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	end := 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		end += size
+	}
+	return text[:end]
+}
+
+func previousWord(text string) string {
+	text = strings.TrimRightFunc(text, unicode.IsSpace)
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		start -= size
+	}
+	return strings.ToLower(text[start:])
+}
+
+// slavicPlural is the form of a noun after a number in Croatian and Russian: 0 (one) for 1, 21, 31..., 1 (few) for
+// 2-4, 22-24... and decimals, otherwise 2 (many).
+func slavicPlural(n int64, fraction bool) int {
+	switch {
+	case fraction:
+		return 1
+	case n%10 == 1 && n%100 != 11:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return 1
+	}
+	return 2
+}
+
+// simplePlural is the form of a noun after a number: 0 (singular) for 1, otherwise 1.
+func simplePlural(n int64, fraction bool) int {
+	if n == 1 && !fraction {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,187 @@
+package ankitts
+
+import "testing"
+
+type numbersTest struct {
+	text     string
+	expected string
+}
+
+func testExpandNumbers(t *testing.T, locale string, tests []numbersTest) {
+	t.Helper()
+	for _, test := range tests {
+		if res := ExpandNumbers(test.text, locale); res != test.expected {
+			t.Errorf("%s %q: expected %q, got %q", locale, test.text, test.expected, res)
+		}
+	}
+}
+
+func TestExpandNumbersGerman(t *testing.T) {
+	testExpandNumbers(t, "de-DE", []numbersTest{
+		{"3,5", "drei Komma fünf"},
+		{"1.234,5", "tausendzweihundertvierunddreißig Komma fünf"},
+		{"2 kg", "zwei Kilogramm"},
+		{"-3 °C", "minus drei Grad Celsius"},
+		{"20 %", "zwanzig Prozent"},
+		{"€ 3,50", "drei Euro und fünfzig Cent"},
+		{"3,50 €", "drei Euro und fünfzig Cent"},
+		{"1.000.000 €", "eine Million Euro"},
+		{"0,99 €", "neunundneunzig Cent"},
+		// Ordinals without an article have the ending of the noun's gender
+		{"3. Platz", "dritter Platz"},
+		{"3. Liga", "dritte Liga"},
+		{"3. Kapitel", "drittes Kapitel"},
+		{"der 2. Weltkrieg", "der zweite Weltkrieg"},
+		{"am 3. Mai", "am dritten Mai"},
+		{"5.3.2024", "fünfter März zweitausendvierundzwanzig"},
+		{"14:30 Uhr", "vierzehn Uhr dreißig"},
+		{"1/2", "ein halb"},
+		{"24/7", "24/7"},
+		// Ein and eine before nouns
+		{"1 Tasse", "eine Tasse"},
+		{"1 Frau", "eine Frau"},
+		{"1 Uhr", "ein Uhr"},
+		{"1 Haus", "ein Haus"},
+		{"1 oder 2", "eins oder zwei"},
+		// Ranges
+		{"Seite 5-10", "Seite fünf bis zehn"},
+		{"5–10 km", "fünf bis zehn Kilometer"},
+		{"von 1990 - 1995", "von neunzehnhundertneunzig bis neunzehnhundertfünfundneunzig"},
+		// Years only after a year word
+		{"im Jahr 1999", "im Jahr neunzehnhundertneunundneunzig"},
+		{"seit 1989", "seit neunzehnhundertneunundachtzig"},
+		{"1999 Teilnehmer", "tausendneunhundertneunundneunzig Teilnehmer"},
+		// Numbers joined by separators stay unchanged
+		{"3.5", "3.5"},
+		{"ISBN 978-3-16-148410-0", "ISBN 978-3-16-148410-0"},
+		{"Version 1.2.3", "Version 1.2.3"},
+		{"0176", "null eins sieben sechs"},
+	})
+}
+
+func TestExpandNumbersEnglish(t *testing.T) {
+	testExpandNumbers(t, "en-GB", []numbersTest{
+		{"3.5", "three point five"},
+		{"1,234.5", "one thousand two hundred thirty-four point five"},
+		{"2 km", "two kilometers"},
+		{"1 kg", "one kilogram"},
+		{"-3 °C", "minus three degrees Celsius"},
+		{"$1.99", "one dollar and ninety-nine cents"},
+		{"$0.50", "fifty cents"},
+		{"3rd place", "third place"},
+		{"5 March 2024", "the fifth of March twenty twenty-four"},
+		{"14:30", "fourteen thirty"},
+		{"2:30 pm", "two thirty PM"},
+		{"3/4", "three quarters"},
+		{"½", "one half"},
+		{"2½", "two and a half"},
+		{"2 1/2 cups", "two and a half cups"},
+		{"1 3/4", "one and three quarters"},
+		// Slashes which aren't fractions
+		{"24/7", "24/7"},
+		{"50/50", "50/50"},
+		{"9/11", "9/11"},
+		{".5", ".5"},
+		{"007", "zero zero seven"},
+		{"A-4", "A-four"},
+		// Ranges with an en dash, a spaced hyphen, or a hyphen between small numbers
+		{"5–10", "five to ten"},
+		{"5 - 10", "five to ten"},
+		{"10-20 %", "ten to twenty percent"},
+		{"Call 555-1234", "Call 555-1234"},
+		{"10-5", "10-5"},
+		// Years only after a year word
+		{"in 1999", "in nineteen ninety-nine"},
+		{"since 2024", "since twenty twenty-four"},
+		{"page 1234", "page one thousand two hundred thirty-four"},
+		{"1500 people", "one thousand five hundred people"},
+		// Numbers joined by separators stay unchanged
+		{"16:9", "16:9"},
+		{"1/0", "1/0"},
+		{"3/101", "3/101"},
+		{"1.2.3.4", "1.2.3.4"},
+	})
+	testExpandNumbers(t, "en-US", []numbersTest{
+		{"3/4/2024", "March fourth, twenty twenty-four"},
+		{"in 1999", "in nineteen ninety-nine"},
+	})
+}
+
+func TestExpandNumbersFrench(t *testing.T) {
+	testExpandNumbers(t, "fr-FR", []numbersTest{
+		{"3,5", "trois virgule cinq"},
+		{"21 €", "vingt et un euros"},
+		{"21 femmes", "vingt et une femmes"},
+		{"21 ans", "vingt et un ans"},
+		{"21 et 22", "vingt et un et vingt-deux"},
+		// Numbers ending in un before a noun of unknown gender
+		{"21 élèves", "21 élèves"},
+		{"22 élèves", "vingt-deux élèves"},
+		{"1er mai", "premier mai"},
+		{"2e étage", "deuxième étage"},
+		{"14:30", "quatorze heures trente"},
+		{"5–10", "cinq à dix"},
+		{"3/4", "trois quarts"},
+		{"80 km", "quatre-vingts kilomètres"},
+		{"06-12-34", "06-12-34"},
+	})
+}
+
+func TestExpandNumbersSpanish(t *testing.T) {
+	testExpandNumbers(t, "es-ES", []numbersTest{
+		{"3,5", "tres coma cinco"},
+		{"21 €", "veintiún euros"},
+		{"1.000.000 €", "un millón de euros"},
+		{"2.000.000 de personas", "dos millones de personas"},
+		{"1 de mayo de 2024", "primero de mayo de dos mil veinticuatro"},
+		{"14:30", "catorce y treinta"},
+		{"1º", "primero"},
+		{"1ª", "primera"},
+		{"5–10", "cinco a diez"},
+		// Uno is shortened before nouns
+		{"21 años", "veintiún años"},
+		{"1 mujer", "una mujer"},
+		{"21 personas", "veintiuna personas"},
+		{"21 estudiantes", "21 estudiantes"},
+		{"21 y 22", "veintiuno y veintidós"},
+		{"21", "veintiuno"},
+	})
+}
+
+func TestExpandNumbersCroatian(t *testing.T) {
+	testExpandNumbers(t, "hr-HR", []numbersTest{
+		{"2 kg", "dva kilograma"},
+		{"5 kg", "pet kilograma"},
+		{"21 kg", "dvadeset jedan kilogram"},
+		{"1,5 kn", "jedna kuna i pedeset lipa"},
+		{"3. svibnja 2024.", "trećeg svibnja dvije tisuće dvadeset četvrte"},
+		{"14:30", "četrnaest i trideset"},
+		{"5-10", "pet do deset"},
+	})
+}
+
+func TestExpandNumbersRussian(t *testing.T) {
+	testExpandNumbers(t, "ru-RU", []numbersTest{
+		{"5 км", "пять километров"},
+		{"2 кг", "два килограмма"},
+		{"21 км", "двадцать один километр"},
+		{"1 000 000 ₽", "миллион рублей"},
+		{"3-й", "третий"},
+		{"5 мая 2024 г.", "пятое мая две тысячи двадцать четвёртого года"},
+		{"14:30", "четырнадцать часов тридцать минут"},
+		{"8-800-555", "8-800-555"},
+	})
+}
+
+func TestExpandNumbersJapanese(t *testing.T) {
+	testExpandNumbers(t, "ja-JP", []numbersTest{
+		{"1,234", "千二百三十四"},
+		{"3.5", "三点五"},
+		{"５円", "五円"},
+		{"100km", "百キロメートル"},
+		{"3/4", "四分の三"},
+		{"2024/3/5", "二千二十四年三月五日"},
+		{"14:30", "十四時三十分"},
+		{"16:9", "16:9"},
+	})
+}
@@ -0,0 +1,999 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// numberLanguages by language, or locale if it differs from the language (en-us)
+var numberLanguages = map[string]numberLanguage{
+	"en":    english(false),
+	"en-us": english(true),
+	"de":    german,
+	"fr":    french,
+	"es":    spanish,
+	"hr":    croatian,
+	"ru":    russian,
+	"ja":    japanese,
+}
+
+// numberScale is a power of ten with a name, like thousand.
+type numberScale struct {
+	value int64
+	name  noun
+}
+
+// digitByDigit spells every digit of str.
+func digitByDigit(str string, cardinal func(int64, gender) string, space string) string {
+	var res []string
+	for _, r := range str {
+		if unicode.IsDigit(r) {
+			res = append(res, cardinal(int64(r-'0'), standalone))
+		}
+	}
+	return strings.Join(res, space)
+}
+
+// replaceLastWord replaces the last word (after a space or hyphen) of str.
+func replaceLastWord(str string, fn func(string) string) string {
+	i := strings.LastIndexAny(str, " -") + 1
+	return str[:i] + fn(str[i:])
+}
+
+func capitalize(str string) string {
+	r, size := utf8.DecodeRuneInString(str)
+	return string(unicode.ToUpper(r)) + str[size:]
+}
+
+func monthsPattern(months []string) string {
+	return "(?P<month>" + strings.Join(months, "|") + ")"
+}
+
+// English
+
+var (
+	enSmall = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven",
+		"twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens      = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enOrdinals  = map[string]string{"one": "first", "two": "second", "three": "third", "five": "fifth", "eight": "eighth", "nine": "ninth", "twelve": "twelfth"}
+	enMonths    = []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	enYearWords = map[string]bool{"in": true, "since": true, "from": true, "until": true, "by": true, "of": true, "before": true, "after": true, "year": true}
+	enScales    = []numberScale{
+		{1000000000000, noun{forms: []string{"trillion"}}},
+		{1000000000, noun{forms: []string{"billion"}}},
+		{1000000, noun{forms: []string{"million"}}},
+		{1000, noun{forms: []string{"thousand"}}},
+	}
+)
+
+func english(us bool) numberLanguage {
+	lang := numberLanguage{
+		thousands: ",", decimal: `\.`, minus: "minus", to: "to", space: " ", and: "and",
+		cardinal: enCardinal,
+		ordinal:  func(n int64, _ string) string { return replaceLastWord(enCardinal(n, standalone), enOrdinalWord) },
+		decimalNumber: func(whole int64, frac string) string {
+			return enCardinal(whole, standalone) + " point " + digitByDigit(frac, enCardinal, " ")
+		},
+		plural:   simplePlural,
+		fraction: enFraction,
+		time:     enTime,
+		months:   enMonths,
+		// Two and a half
+		mixedFraction: func(num, den int64) string {
+			if num == 1 {
+				return "a " + strings.TrimPrefix(enFraction(num, den), "one ")
+			}
+			return enFraction(num, den)
+		},
+		monthDates: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?P<day>\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthsPattern(enMonths) + `(?:,?\s+(?P<year>\d{4}))?`),
+			regexp.MustCompile(`(?i)` + monthsPattern(enMonths) + `\s+(?P<day>\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(?P<year>\d{4}))?`),
+		},
+		ordinals:    regexp.MustCompile(`(\d+)(st|nd|rd|th)`),
+		ordinalForm: func(suffix, before, after string) string { return "" },
+		special: func(n int64, before string) (string, bool) {
+			if n >= 1100 && n < 2100 && enYearWords[before] {
+				return enYear(int(n)), true
+			}
+			return "", false
+		},
+		dateOrder: "dmy", dateSep: "/",
+		units: map[string]noun{
+			"km": {forms: []string{"kilometer", "kilometers"}}, "m": {forms: []string{"meter", "meters"}},
+			"cm": {forms: []string{"centimeter", "centimeters"}}, "mm": {forms: []string{"millimeter", "millimeters"}},
+			"kg": {forms: []string{"kilogram", "kilograms"}}, "g": {forms: []string{"gram", "grams"}},
+			"mg": {forms: []string{"milligram", "milligrams"}}, "l": {forms: []string{"liter", "liters"}},
+			"ml": {forms: []string{"milliliter", "milliliters"}}, "km/h": {forms: []string{"kilometer per hour", "kilometers per hour"}},
+			"mph": {forms: []string{"mile per hour", "miles per hour"}}, "km²": {forms: []string{"square kilometer", "square kilometers"}},
+			"m²": {forms: []string{"square meter", "square meters"}}, "h": {forms: []string{"hour", "hours"}},
+			"min": {forms: []string{"minute", "minutes"}}, "°C": {forms: []string{"degree Celsius", "degrees Celsius"}},
+			"°F": {forms: []string{"degree Fahrenheit", "degrees Fahrenheit"}}, "°": {forms: []string{"degree", "degrees"}},
+			"%": {forms: []string{"percent"}},
+		},
+		currencies: map[string]currency{
+			"€":  {noun{forms: []string{"euro", "euros"}}, noun{forms: []string{"cent", "cents"}}},
+			"$":  {noun{forms: []string{"dollar", "dollars"}}, noun{forms: []string{"cent", "cents"}}},
+			"£":  {noun{forms: []string{"pound", "pounds"}}, noun{forms: []string{"penny", "pence"}}},
+			"¥":  {noun{forms: []string{"yen"}}, noun{}},
+			"₽":  {noun{forms: []string{"ruble", "rubles"}}, noun{forms: []string{"kopeck", "kopecks"}}},
+			"kn": {noun{forms: []string{"kuna", "kunas"}}, noun{forms: []string{"lipa"}}},
+		},
+	}
+	lang.date = func(day, month, year int, before string) string {
+		var res string
+		if us {
+			res = enMonths[month-1] + " " + lang.ordinal(int64(day), "")
+			if year > 0 {
+				res += ", " + enYear(year)
+			}
+			return res
+		}
+		if before != "the" {
+			res = "the "
+		}
+		res += lang.ordinal(int64(day), "") + " of " + enMonths[month-1]
+		if year > 0 {
+			res += " " + enYear(year)
+		}
+		return res
+	}
+	if us {
+		lang.dateOrder = "mdy"
+	}
+	return lang
+}
+
+func enCardinal(n int64, _ gender) string {
+	switch {
+	case n < 20:
+		return enSmall[n]
+	case n < 100:
+		if n%10 == 0 {
+			return enTens[n/10]
+		}
+		return enTens[n/10] + "-" + enSmall[n%10]
+	case n < 1000:
+		if n%100 == 0 {
+			return enSmall[n/100] + " hundred"
+		}
+		return enSmall[n/100] + " hundred " + enCardinal(n%100, standalone)
+	}
+	var parts []string
+	for _, s := range enScales {
+		if n >= s.value {
+			parts = append(parts, enCardinal(n/s.value, standalone)+" "+s.name.forms[0])
+			n %= s.value
+		}
+	}
+	if n > 0 {
+		parts = append(parts, enCardinal(n, standalone))
+	}
+	return strings.Join(parts, " ")
+}
+
+func enOrdinalWord(word string) string {
+	if ordinal, found := enOrdinals[word]; found {
+		return ordinal
+	}
+	if strings.HasSuffix(word, "y") {
+		return strings.TrimSuffix(word, "y") + "ieth"
+	}
+	return word + "th"
+}
+
+// enYear reads years in pairs: nineteen ninety-nine, twenty twenty-four, but two thousand five.
+func enYear(year int) string {
+	if year < 1100 || year%1000 < 10 || year >= 10000 {
+		return enCardinal(int64(year), standalone)
+	}
+	high, low := int64(year/100), int64(year%100)
+	switch {
+	case low == 0:
+		return enCardinal(high, standalone) + " hundred"
+	case low < 10:
+		return enCardinal(high, standalone) + " oh " + enCardinal(low, standalone)
+	}
+	return enCardinal(high, standalone) + " " + enCardinal(low, standalone)
+}
+
+func enFraction(num, den int64) string {
+	var word string
+	switch den {
+	case 2:
+		word = "half"
+		if num != 1 {
+			word = "halves"
+		}
+	case 4:
+		word = "quarter"
+	default:
+		word = replaceLastWord(enCardinal(den, standalone), enOrdinalWord)
+	}
+	if num != 1 && den != 2 {
+		word += "s"
+	}
+	return enCardinal(num, standalone) + " " + word
+}
+
+func enTime(hour, minute int, suffix string) string {
+	if suffix != "" && hour > 12 {
+		hour -= 12
+	}
+	res := enCardinal(int64(hour), standalone)
+	switch {
+	case minute == 0 && (hour <= 12 || suffix != ""):
+		res += " o'clock"
+	case minute == 0:
+		res += " hundred"
+	case minute < 10:
+		res += " oh " + enCardinal(int64(minute), standalone)
+	default:
+		res += " " + enCardinal(int64(minute), standalone)
+	}
+	if suffix != "" {
+		res += " " + suffix + "M"
+	}
+	return res
+}
+
+// German
+
+var (
+	deSmall = []string{"null", "eins", "zwei", "drei", "vier", "fünf", "sechs", "sieben", "acht", "neun", "zehn", "elf",
+		"zwölf", "dreizehn", "vierzehn", "fünfzehn", "sechzehn", "siebzehn", "achtzehn", "neunzehn"}
+	deTens         = []string{"", "", "zwanzig", "dreißig", "vierzig", "fünfzig", "sechzig", "siebzig", "achtzig", "neunzig"}
+	deOrdinalStems = map[int64]string{1: "erst", 3: "dritt", 7: "siebt", 8: "acht"}
+	deMonths       = []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}
+	deYearWords    = map[string]bool{"im": true, "jahr": true, "jahre": true, "jahres": true, "seit": true, "ab": true, "bis": true, "von": true, "vor": true, "nach": true, "um": true, "anno": true}
+	deDativeWords  = map[string]bool{"am": true, "im": true, "vom": true, "zum": true, "beim": true, "zur": true, "dem": true, "den": true, "des": true, "einem": true, "einen": true, "einer": true, "eines": true, "seit": true, "ab": true, "bis": true}
+	deArticles     = map[string]bool{"der": true, "die": true, "das": true, "dieser": true, "diese": true, "dieses": true, "jeder": true, "jede": true, "jedes": true, "welcher": true, "welche": true, "welches": true}
+	deLargeScales  = []numberScale{
+		{1000000000000, noun{forms: []string{"Billion", "Billionen"}, feminine: true}},
+		{1000000000, noun{forms: []string{"Milliarde", "Milliarden"}, feminine: true}},
+		{1000000, noun{forms: []string{"Million", "Millionen"}, feminine: true}},
+	}
+)
+
+// Genders of nouns after numbers and ordinals, see german.attributive
+var deNounGenders = nounGenders{
+	words: map[string]string{"ende": "n", "auge": "n", "erbe": "n", "interesse": "n", "käse": "m", "name": "m", "junge": "m",
+		"kunde": "m", "gedanke": "m", "glaube": "m", "frau": "f", "hand": "f", "nacht": "f", "stadt": "f", "zeit": "f",
+		"welt": "f", "tür": "f", "bank": "f", "zahl": "f", "wahl": "f", "art": "f", "form": "f", "liga": "f", "maus": "f"},
+	endings: map[string]string{"e": "f", "ung": "f", "heit": "f", "keit": "f", "schaft": "f", "ion": "f", "tät": "f",
+		"ei": "f", "ie": "f", "ik": "f", "ur": "f", "chen": "n", "lein": "n", "ment": "n", "tum": "n", "um": "n", "o": "n",
+		"nis": "n", "haus": "n", "buch": "n", "spiel": "n", "jahr": "n", "kind": "n", "zimmer": "n", "land": "n",
+		"mal": "n", "kapitel": "n", "stück": "n", "bild": "n", "wort": "n", "ziel": "n", "baum": "m", "raum": "m",
+		"traum": "m", "euro": "m", "flur": "m", "abitur": "n"},
+}
+
+var german = numberLanguage{
+	thousands: `\.`, decimal: ",", minus: "minus", to: "bis", space: " ", and: "und",
+	cardinal: deCardinal,
+	ordinal:  func(n int64, form string) string { return deOrdinalStem(n) + form },
+	decimalNumber: func(whole int64, frac string) string {
+		return deCardinal(whole, standalone) + " Komma " + digitByDigit(frac, deCardinal, " ")
+	},
+	plural:   simplePlural,
+	fraction: deFraction,
+	date: func(day, month, year int, before string) string {
+		form := "er"
+		if deDativeWords[before] {
+			form = "en"
+		}
+		res := deOrdinalStem(int64(day)) + form + " " + deMonths[month-1]
+		if year > 0 {
+			res += " " + deYear(year)
+		}
+		return res
+	},
+	time: func(hour, minute int, _ string) string {
+		res := deCardinal(int64(hour), masculine) + " Uhr"
+		if minute > 0 {
+			res += " " + deCardinal(int64(minute), standalone)
+		}
+		return res
+	},
+	dateOrder: "dmy", dateSep: `\.`,
+	months: deMonths,
+	monthDates: []*regexp.Regexp{
+		regexp.MustCompile(`(?P<day>\d{1,2})\.\s*` + monthsPattern(deMonths) + `(?:\s+(?P<year>\d{4}))?`),
+	},
+	// 3. Platz, the following word is kept
+	ordinals: regexp.MustCompile(`(\d+)(\.)(\s+\p{L}+)`),
+	ordinalForm: func(suffix, before, after string) string {
+		switch {
+		case deDativeWords[before]:
+			return "en"
+		case deArticles[before]:
+			return "e"
+		}
+		// Without an article the ending shows the gender: dritter Platz, dritte Liga, drittes Kapitel
+		switch deNounGenders.of(strings.ToLower(after)) {
+		case "f":
+			return "e"
+		case "n":
+			return "es"
+		}
+		return "er"
+	},
+	special: func(n int64, before string) (string, bool) {
+		if n >= 1100 && n < 2000 && deYearWords[before] {
+			return deYear(int(n)), true
+		}
+		return "", false
+	},
+	units: map[string]noun{
+		"km": {forms: []string{"Kilometer"}}, "m": {forms: []string{"Meter"}}, "cm": {forms: []string{"Zentimeter"}},
+		"mm": {forms: []string{"Millimeter"}}, "kg": {forms: []string{"Kilogramm"}}, "g": {forms: []string{"Gramm"}},
+		"mg": {forms: []string{"Milligramm"}}, "l": {forms: []string{"Liter"}}, "ml": {forms: []string{"Milliliter"}},
+		"km/h": {forms: []string{"Kilometer pro Stunde"}}, "mph": {forms: []string{"Meile pro Stunde", "Meilen pro Stunde"}, feminine: true},
+		"km²": {forms: []string{"Quadratkilometer"}}, "m²": {forms: []string{"Quadratmeter"}},
+		"h": {forms: []string{"Stunde", "Stunden"}, feminine: true}, "min": {forms: []string{"Minute", "Minuten"}, feminine: true},
+		"°C": {forms: []string{"Grad Celsius"}}, "°F": {forms: []string{"Grad Fahrenheit"}}, "°": {forms: []string{"Grad"}},
+		"%": {forms: []string{"Prozent"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"Euro"}}, noun{forms: []string{"Cent"}}},
+		"$":  {noun{forms: []string{"Dollar"}}, noun{forms: []string{"Cent"}}},
+		"£":  {noun{forms: []string{"Pfund"}}, noun{forms: []string{"Penny", "Pence"}}},
+		"¥":  {noun{forms: []string{"Yen"}}, noun{}},
+		"₽":  {noun{forms: []string{"Rubel"}}, noun{forms: []string{"Kopeke", "Kopeken"}, feminine: true}},
+		"kn": {noun{forms: []string{"Kuna"}, feminine: true}, noun{forms: []string{"Lipa"}, feminine: true}},
+	},
+	// Eins is ein or eine before nouns, which are capitalized: ein Uhr, eine Tasse
+	attributive: func(after string) (gender, bool) {
+		first, _ := utf8.DecodeRuneInString(after)
+		if !unicode.IsUpper(first) || deArticles[strings.ToLower(after)] {
+			return standalone, true
+		}
+		if deNounGenders.of(strings.ToLower(after)) == "f" {
+			return feminine, true
+		}
+		return masculine, true
+	},
+}
+
+// deBelow1000 spells 1 to 999 in one word, with "eins" at the end of a number (hunderteins) and "ein" before a scale
+// (hunderteintausend).
+func deBelow1000(n int64, final bool) string {
+	var res string
+	if n >= 100 {
+		if n/100 > 1 {
+			res = deSmall[n/100]
+		}
+		res += "hundert"
+		n %= 100
+	}
+	switch {
+	case n == 0:
+	case n == 1 && !final:
+		res += "ein"
+	case n < 20:
+		res += deSmall[n]
+	case n%10 == 0:
+		res += deTens[n/10]
+	case n%10 == 1:
+		res += "einund" + deTens[n/10]
+	default:
+		res += deSmall[n%10] + "und" + deTens[n/10]
+	}
+	return res
+}
+
+func deCardinal(n int64, g gender) string {
+	switch {
+	case n == 0:
+		return "null"
+	case n == 1 && g == masculine:
+		return "ein"
+	case n == 1 && g == feminine:
+		return "eine"
+	}
+	var parts []string
+	for _, s := range deLargeScales {
+		if n < s.value {
+			continue
+		}
+		count := n / s.value
+		n %= s.value
+		if count == 1 {
+			parts = append(parts, "eine "+s.name.forms[0])
+		} else {
+			parts = append(parts, deCardinal(count, feminine)+" "+s.name.forms[1])
+		}
+	}
+	var word string
+	if n >= 1000 {
+		if n/1000 > 1 {
+			word = deBelow1000(n/1000, false)
+		}
+		word += "tausend"
+		n %= 1000
+	}
+	if n > 0 {
+		word += deBelow1000(n, true)
+	}
+	if word != "" {
+		parts = append(parts, word)
+	}
+	return strings.Join(parts, " ")
+}
+
+// deOrdinalStem is the ordinal without the ending: erst, zwanzigst, hundertdritt.
+func deOrdinalStem(n int64) string {
+	rest := n % 100
+	if rest == 0 || rest >= 20 {
+		return deCardinal(n, standalone) + "st"
+	}
+	var res string
+	if n >= 100 {
+		res = deCardinal(n-rest, standalone)
+	}
+	if stem, found := deOrdinalStems[rest]; found {
+		return res + stem
+	}
+	return res + deSmall[rest] + "t"
+}
+
+// deYear reads years before 2000 in hundreds: neunzehnhundertneunundneunzig.
+func deYear(year int) string {
+	if year >= 1100 && year < 2000 {
+		return deBelow1000(int64(year/100), false) + "hundert" + deBelow1000(int64(year%100), true)
+	}
+	return deCardinal(int64(year), standalone)
+}
+
+func deFraction(num, den int64) string {
+	if den == 2 {
+		if num == 1 {
+			return "ein halb"
+		}
+		return deCardinal(num, standalone) + " halbe"
+	}
+	return deCardinal(num, masculine) + " " + capitalize(deOrdinalStem(den)+"el")
+}
+
+// French
+
+var (
+	frSmall = []string{"zéro", "un", "deux", "trois", "quatre", "cinq", "six", "sept", "huit", "neuf", "dix", "onze", "douze",
+		"treize", "quatorze", "quinze", "seize", "dix-sept", "dix-huit", "dix-neuf"}
+	frTens   = []string{"", "dix", "vingt", "trente", "quarante", "cinquante", "soixante"}
+	frMonths = []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}
+	frScales = []numberScale{
+		{1000000000000, noun{forms: []string{"billion", "billions"}}},
+		{1000000000, noun{forms: []string{"milliard", "milliards"}}},
+		{1000000, noun{forms: []string{"million", "millions"}}},
+	}
+)
+
+// Words after numbers which aren't nouns, see french.attributive
+var frNotNouns = map[string]bool{"et": true, "ou": true, "à": true, "de": true, "des": true, "du": true, "en": true,
+	"pour": true, "par": true, "sur": true, "avec": true, "que": true, "est": true, "sont": true, "plus": true, "moins": true}
+
+// Genders of nouns after numbers, unknown for most, see french.attributive
+var frNounGenders = nounGenders{
+	words: map[string]string{"fois": "f", "femme": "f", "femmes": "f", "fille": "f", "filles": "f", "personne": "f",
+		"personnes": "f", "heure": "f", "heures": "f", "minute": "f", "minutes": "f", "seconde": "f", "secondes": "f",
+		"semaine": "f", "semaines": "f", "page": "f", "pages": "f", "image": "f", "images": "f", "chose": "f", "choses": "f",
+		"maison": "f", "maisons": "f", "voiture": "f", "voitures": "f", "nuit": "f", "nuits": "f", "ville": "f", "villes": "f",
+		"an": "m", "ans": "m", "jour": "m", "jours": "m", "mois": "m", "homme": "m", "hommes": "m", "enfant": "m",
+		"enfants": "m", "siècle": "m", "siècles": "m", "livre": "m", "livres": "m", "musée": "m", "musées": "m",
+		"point": "m", "points": "m", "euro": "m", "euros": "m", "dollar": "m", "dollars": "m"},
+	endings: map[string]string{"tion": "f", "tions": "f", "sion": "f", "sions": "f", "té": "f", "tés": "f", "ure": "f",
+		"ures": "f", "ette": "f", "ettes": "f", "ence": "f", "ences": "f", "ance": "f", "ances": "f", "ée": "f", "ées": "f",
+		"ie": "f", "ies": "f", "ment": "m", "ments": "m", "age": "m", "ages": "m", "eau": "m", "eaux": "m", "isme": "m",
+		"ismes": "m", "oir": "m", "oirs": "m", "ier": "m", "iers": "m"},
+}
+
+var french = numberLanguage{
+	thousands: `[ \x{a0}\x{202f}]`, decimal: ",", minus: "moins", to: "à", space: " ", and: "et",
+	cardinal: frCardinal,
+	ordinal:  frOrdinal,
+	decimalNumber: func(whole int64, frac string) string {
+		return frCardinal(whole, standalone) + " virgule " + decimalDigits(frac, frCardinal, " ")
+	},
+	plural: func(n int64, fraction bool) int {
+		if n < 2 {
+			return 0
+		}
+		return 1
+	},
+	fraction: func(num, den int64) string {
+		var word string
+		switch den {
+		case 2:
+			word = "demi"
+		case 3:
+			word = "tiers"
+		case 4:
+			word = "quart"
+		default:
+			word = frOrdinal(den, "")
+		}
+		if num > 1 && den != 3 {
+			word += "s"
+		}
+		return frCardinal(num, standalone) + " " + word
+	},
+	date: func(day, month, year int, before string) string {
+		res := frCardinal(int64(day), standalone)
+		if day == 1 {
+			res = "premier"
+		}
+		res += " " + frMonths[month-1]
+		if year > 0 {
+			res += " " + frCardinal(int64(year), standalone)
+		}
+		return res
+	},
+	time: func(hour, minute int, _ string) string {
+		res := frCardinal(int64(hour), feminine) + " heure"
+		if hour > 1 {
+			res += "s"
+		}
+		if minute > 0 {
+			res += " " + frCardinal(int64(minute), standalone)
+		}
+		return res
+	},
+	dateOrder: "dmy", dateSep: "/",
+	months: frMonths,
+	monthDates: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?P<day>\d{1,2})(?:er)?\s+` + monthsPattern(frMonths) + `(?:\s+(?P<year>\d{4}))?`),
+	},
+	ordinals: regexp.MustCompile(`(\d+)(ère|ème|er|re|e)`),
+	ordinalForm: func(suffix, before, after string) string {
+		if suffix == "re" || suffix == "ère" {
+			return "f"
+		}
+		return ""
+	},
+	units: map[string]noun{
+		"km": {forms: []string{"kilomètre", "kilomètres"}}, "m": {forms: []string{"mètre", "mètres"}},
+		"cm": {forms: []string{"centimètre", "centimètres"}}, "mm": {forms: []string{"millimètre", "millimètres"}},
+		"kg": {forms: []string{"kilogramme", "kilogrammes"}}, "g": {forms: []string{"gramme", "grammes"}},
+		"mg": {forms: []string{"milligramme", "milligrammes"}}, "l": {forms: []string{"litre", "litres"}},
+		"ml": {forms: []string{"millilitre", "millilitres"}}, "km/h": {forms: []string{"kilomètre par heure", "kilomètres par heure"}},
+		"mph": {forms: []string{"mile par heure", "miles par heure"}}, "km²": {forms: []string{"kilomètre carré", "kilomètres carrés"}},
+		"m²": {forms: []string{"mètre carré", "mètres carrés"}}, "h": {forms: []string{"heure", "heures"}, feminine: true},
+		"min": {forms: []string{"minute", "minutes"}, feminine: true}, "°C": {forms: []string{"degré Celsius", "degrés Celsius"}},
+		"°F": {forms: []string{"degré Fahrenheit", "degrés Fahrenheit"}}, "°": {forms: []string{"degré", "degrés"}},
+		"%": {forms: []string{"pour cent"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"euro", "euros"}}, noun{forms: []string{"centime", "centimes"}}},
+		"$":  {noun{forms: []string{"dollar", "dollars"}}, noun{forms: []string{"cent", "cents"}}},
+		"£":  {noun{forms: []string{"livre", "livres"}, feminine: true}, noun{forms: []string{"penny", "pence"}}},
+		"¥":  {noun{forms: []string{"yen", "yens"}}, noun{}},
+		"₽":  {noun{forms: []string{"rouble", "roubles"}}, noun{forms: []string{"kopeck", "kopecks"}}},
+		"kn": {noun{forms: []string{"kuna", "kunas"}, feminine: true}, noun{forms: []string{"lipa", "lipas"}, feminine: true}},
+	},
+	// Un is une before feminine nouns: vingt et une femmes
+	attributive: func(after string) (gender, bool) {
+		after = strings.ToLower(after)
+		if after == "" || frNotNouns[after] {
+			return standalone, true
+		}
+		switch frNounGenders.of(after) {
+		case "f":
+			return feminine, true
+		case "m":
+			return masculine, true
+		}
+		return standalone, false
+	},
+}
+
+// decimalDigits reads the decimals as a number, or digit by digit if they start with 0.
+func decimalDigits(frac string, cardinal func(int64, gender) string, space string) string {
+	if strings.HasPrefix(frac, "0") || len(frac) > 3 {
+		return digitByDigit(frac, cardinal, space)
+	}
+	var n int64
+	for _, r := range frac {
+		n = n*10 + int64(r-'0')
+	}
+	return cardinal(n, standalone)
+}
+
+// frBelow100 spells 0 to 99, final is false before cent and mille (quatre-vingt mille).
+func frBelow100(n int64, final bool) string {
+	switch {
+	case n < 20:
+		return frSmall[n]
+	case n < 70:
+		switch n % 10 {
+		case 0:
+			return frTens[n/10]
+		case 1:
+			return frTens[n/10] + " et un"
+		}
+		return frTens[n/10] + "-" + frSmall[n%10]
+	case n < 80:
+		if n == 71 {
+			return "soixante et onze"
+		}
+		return "soixante-" + frSmall[n-60]
+	case n == 80 && final:
+		return "quatre-vingts"
+	case n == 80:
+		return "quatre-vingt"
+	}
+	return "quatre-vingt-" + frSmall[n-80]
+}
+
+func frBelow1000(n int64, final bool) string {
+	var parts []string
+	switch hundreds := n / 100; {
+	case hundreds == 1:
+		parts = append(parts, "cent")
+	case hundreds > 1 && n%100 == 0 && final:
+		parts = append(parts, frSmall[hundreds]+" cents")
+	case hundreds > 1:
+		parts = append(parts, frSmall[hundreds]+" cent")
+	}
+	if n%100 > 0 || n == 0 {
+		parts = append(parts, frBelow100(n%100, final))
+	}
+	return strings.Join(parts, " ")
+}
+
+func frCardinal(n int64, g gender) string {
+	var parts []string
+	for _, s := range frScales {
+		if n < s.value {
+			continue
+		}
+		count := n / s.value
+		n %= s.value
+		if count == 1 {
+			parts = append(parts, "un "+s.name.forms[0])
+		} else {
+			parts = append(parts, frCardinal(count, standalone)+" "+s.name.forms[1])
+		}
+	}
+	if n >= 1000 {
+		if n/1000 == 1 {
+			parts = append(parts, "mille")
+		} else {
+			parts = append(parts, frBelow1000(n/1000, false)+" mille")
+		}
+		n %= 1000
+	}
+	if n > 0 || len(parts) == 0 {
+		parts = append(parts, frBelow1000(n, true))
+	}
+	res := strings.Join(parts, " ")
+	if g == feminine && (res == "un" || strings.HasSuffix(res, " un")) {
+		res += "e"
+	}
+	return res
+}
+
+func frOrdinal(n int64, form string) string {
+	if n == 1 {
+		if form == "f" {
+			return "première"
+		}
+		return "premier"
+	}
+	return replaceLastWord(frCardinal(n, standalone), func(word string) string {
+		switch word {
+		case "cinq":
+			return "cinquième"
+		case "neuf":
+			return "neuvième"
+		case "vingts", "cents":
+			word = strings.TrimSuffix(word, "s")
+		}
+		return strings.TrimSuffix(word, "e") + "ième"
+	})
+}
+
+// Spanish
+
+var (
+	esSmall = []string{"cero", "uno", "dos", "tres", "cuatro", "cinco", "seis", "siete", "ocho", "nueve", "diez", "once",
+		"doce", "trece", "catorce", "quince", "dieciséis", "diecisiete", "dieciocho", "diecinueve", "veinte", "veintiuno",
+		"veintidós", "veintitrés", "veinticuatro", "veinticinco", "veintiséis", "veintisiete", "veintiocho", "veintinueve"}
+	esTens     = []string{"", "", "", "treinta", "cuarenta", "cincuenta", "sesenta", "setenta", "ochenta", "noventa"}
+	esHundreds = []string{"", "ciento", "doscientos", "trescientos", "cuatrocientos", "quinientos", "seiscientos",
+		"setecientos", "ochocientos", "novecientos"}
+	esOrdinals = []string{"", "primero", "segundo", "tercero", "cuarto", "quinto", "sexto", "séptimo", "octavo", "noveno", "décimo"}
+	esMonths   = []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}
+)
+
+// Words after numbers which aren't nouns, see spanish.attributive
+var esNotNouns = map[string]bool{"y": true, "o": true, "u": true, "e": true, "a": true, "de": true, "del": true, "en": true,
+	"con": true, "por": true, "para": true, "que": true, "es": true, "son": true, "más": true, "menos": true}
+
+// Genders of nouns after numbers, see spanish.attributive
+var esNounGenders = nounGenders{
+	words: map[string]string{"mujer": "f", "mujeres": "f", "madre": "f", "madres": "f", "noche": "f", "noches": "f",
+		"calle": "f", "calles": "f", "clase": "f", "clases": "f", "gente": "f", "parte": "f", "partes": "f", "llave": "f",
+		"llaves": "f", "flor": "f", "flores": "f", "vez": "f", "veces": "f", "mano": "f", "manos": "f", "foto": "f",
+		"fotos": "f", "moto": "f", "motos": "f", "imagen": "f", "imágenes": "f", "frase": "f", "frases": "f",
+		"día": "m", "días": "m", "mapa": "m", "mapas": "m", "problema": "m", "problemas": "m", "programa": "m",
+		"programas": "m", "idioma": "m", "idiomas": "m", "sistema": "m", "sistemas": "m", "tema": "m", "temas": "m",
+		"planeta": "m", "planetas": "m", "hombre": "m", "hombres": "m", "padre": "m", "padres": "m", "nombre": "m",
+		"nombres": "m", "coche": "m", "coches": "m", "viaje": "m", "viajes": "m", "mes": "m", "meses": "m", "país": "m",
+		"países": "m", "hotel": "m", "hoteles": "m", "euro": "m", "euros": "m", "dólar": "m", "dólares": "m"},
+	endings: map[string]string{"a": "f", "as": "f", "ión": "f", "iones": "f", "dad": "f", "dades": "f", "tad": "f",
+		"tades": "f", "tud": "f", "tudes": "f", "umbre": "f", "umbres": "f", "o": "m", "os": "m", "or": "m", "ores": "m",
+		"aje": "m", "ajes": "m", "ón": "m", "ones": "m"},
+}
+
+var spanish = numberLanguage{
+	thousands: `[. \x{a0}\x{202f}]`, decimal: ",", minus: "menos", to: "a", space: " ", and: "con", scaleOf: "de",
+	cardinal: esCardinal,
+	ordinal:  esOrdinal,
+	decimalNumber: func(whole int64, frac string) string {
+		return esCardinal(whole, standalone) + " coma " + decimalDigits(frac, esCardinal, " ")
+	},
+	plural: simplePlural,
+	fraction: func(num, den int64) string {
+		var word string
+		switch {
+		case den == 2:
+			word = "medio"
+		case den == 3:
+			word = "tercio"
+		case den <= 10:
+			word = esOrdinals[den]
+		default:
+			word = esCardinal(den, standalone) + "avo"
+		}
+		if num > 1 {
+			word += "s"
+		}
+		return esCardinal(num, masculine) + " " + word
+	},
+	date: func(day, month, year int, before string) string {
+		res := esCardinal(int64(day), standalone)
+		if day == 1 {
+			res = "primero"
+		}
+		res += " de " + esMonths[month-1]
+		if year > 0 {
+			res += " de " + esCardinal(int64(year), standalone)
+		}
+		return res
+	},
+	time: func(hour, minute int, _ string) string {
+		res := esCardinal(int64(hour), feminine)
+		if minute == 0 {
+			return res + " en punto"
+		}
+		return res + " y " + esCardinal(int64(minute), standalone)
+	},
+	dateOrder: "dmy", dateSep: "/",
+	months: esMonths,
+	monthDates: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?P<day>\d{1,2})\s+de\s+` + monthsPattern(esMonths) + `(?:\s+del?\s+(?P<year>\d{4}))?`),
+	},
+	ordinals: regexp.MustCompile(`(\d+)\.?(º|ª|er)`),
+	ordinalForm: func(suffix, before, after string) string {
+		switch suffix {
+		case "ª":
+			return "a"
+		case "er":
+			return "er"
+		}
+		return "o"
+	},
+	units: map[string]noun{
+		"km": {forms: []string{"kilómetro", "kilómetros"}}, "m": {forms: []string{"metro", "metros"}},
+		"cm": {forms: []string{"centímetro", "centímetros"}}, "mm": {forms: []string{"milímetro", "milímetros"}},
+		"kg": {forms: []string{"kilogramo", "kilogramos"}}, "g": {forms: []string{"gramo", "gramos"}},
+		"mg": {forms: []string{"miligramo", "miligramos"}}, "l": {forms: []string{"litro", "litros"}},
+		"ml": {forms: []string{"mililitro", "mililitros"}}, "km/h": {forms: []string{"kilómetro por hora", "kilómetros por hora"}},
+		"mph": {forms: []string{"milla por hora", "millas por hora"}, feminine: true}, "km²": {forms: []string{"kilómetro cuadrado", "kilómetros cuadrados"}},
+		"m²": {forms: []string{"metro cuadrado", "metros cuadrados"}}, "h": {forms: []string{"hora", "horas"}, feminine: true},
+		"min": {forms: []string{"minuto", "minutos"}}, "°C": {forms: []string{"grado Celsius", "grados Celsius"}},
+		"°F": {forms: []string{"grado Fahrenheit", "grados Fahrenheit"}}, "°": {forms: []string{"grado", "grados"}},
+		"%": {forms: []string{"por ciento"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"euro", "euros"}}, noun{forms: []string{"céntimo", "céntimos"}}},
+		"$":  {noun{forms: []string{"dólar", "dólares"}}, noun{forms: []string{"centavo", "centavos"}}},
+		"£":  {noun{forms: []string{"libra", "libras"}, feminine: true}, noun{forms: []string{"penique", "peniques"}}},
+		"¥":  {noun{forms: []string{"yen", "yenes"}}, noun{}},
+		"₽":  {noun{forms: []string{"rublo", "rublos"}}, noun{forms: []string{"kopek", "kopeks"}}},
+		"kn": {noun{forms: []string{"kuna", "kunas"}, feminine: true}, noun{forms: []string{"lipa", "lipas"}, feminine: true}},
+	},
+	// Uno is shortened before masculine nouns (veintiún años) and una before feminine nouns
+	attributive: func(after string) (gender, bool) {
+		after = strings.ToLower(after)
+		if after == "" || esNotNouns[after] {
+			return standalone, true
+		}
+		switch esNounGenders.of(after) {
+		case "f":
+			return feminine, true
+		case "m":
+			return masculine, true
+		}
+		return standalone, false
+	},
+}
+
+func esBelow1000(n int64) string {
+	if n == 100 {
+		return "cien"
+	}
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, esHundreds[n/100])
+	}
+	switch rest := n % 100; {
+	case rest == 0 && n >= 100:
+	case rest < 30:
+		parts = append(parts, esSmall[rest])
+	case rest%10 == 0:
+		parts = append(parts, esTens[rest/10])
+	default:
+		parts = append(parts, esTens[rest/10]+" y "+esSmall[rest%10])
+	}
+	return strings.Join(parts, " ")
+}
+
+// esGender changes a final uno to un before masculine nouns (veintiún euros) and to una before feminine nouns.
+func esGender(str string, g gender) string {
+	switch {
+	case g == masculine && strings.HasSuffix(str, "veintiuno"):
+		return strings.TrimSuffix(str, "veintiuno") + "veintiún"
+	case g == masculine && strings.HasSuffix(str, "uno"):
+		return strings.TrimSuffix(str, "o")
+	case g == feminine && strings.HasSuffix(str, "uno"):
+		return strings.TrimSuffix(str, "o") + "a"
+	}
+	return str
+}
+
+func esCardinal(n int64, g gender) string {
+	var parts []string
+	if n >= 1000000000000 {
+		count := n / 1000000000000
+		if count == 1 {
+			parts = append(parts, "un billón")
+		} else {
+			parts = append(parts, esCardinal(count, masculine)+" billones")
+		}
+		n %= 1000000000000
+	}
+	if n >= 1000000 {
+		count := n / 1000000
+		if count == 1 {
+			parts = append(parts, "un millón")
+		} else {
+			parts = append(parts, esCardinal(count, masculine)+" millones")
+		}
+		n %= 1000000
+	}
+	if n >= 1000 {
+		if n/1000 == 1 {
+			parts = append(parts, "mil")
+		} else {
+			parts = append(parts, esGender(esBelow1000(n/1000), masculine)+" mil")
+		}
+		n %= 1000
+	}
+	if n > 0 || len(parts) == 0 {
+		parts = append(parts, esBelow1000(n))
+	}
+	return esGender(strings.Join(parts, " "), g)
+}
+
+func esOrdinal(n int64, form string) string {
+	if n > 10 {
+		if form == "a" {
+			return esCardinal(n, feminine)
+		}
+		return esCardinal(n, standalone)
+	}
+	res := esOrdinals[n]
+	switch {
+	case form == "a":
+		return strings.TrimSuffix(res, "o") + "a"
+	case form == "er" && (n == 1 || n == 3):
+		return strings.TrimSuffix(res, "o")
+	}
+	return res
+}
+
+// Japanese
+
+var (
+	jaDigits = []string{"ゼロ", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	jaScales = []numberScale{
+		{1000000000000, noun{forms: []string{"兆"}}},
+		{100000000, noun{forms: []string{"億"}}},
+		{10000, noun{forms: []string{"万"}}},
+	}
+)
+
+var japanese = numberLanguage{
+	thousands: ",", decimal: `\.`, minus: "マイナス", to: "から", space: "",
+	cardinal: jaCardinal,
+	ordinal:  func(n int64, _ string) string { return jaCardinal(n, standalone) + "番目" },
+	decimalNumber: func(whole int64, frac string) string {
+		res := jaCardinal(whole, standalone) + "点"
+		for _, r := range frac {
+			res += jaDigits[r-'0']
+		}
+		return res
+	},
+	plural: func(n int64, fraction bool) int { return 0 },
+	fraction: func(num, den int64) string {
+		return jaCardinal(den, standalone) + "分の" + jaCardinal(num, standalone)
+	},
+	date: func(day, month, year int, before string) string {
+		var res string
+		if year > 0 {
+			res = jaCardinal(int64(year), standalone) + "年"
+		}
+		return res + jaCardinal(int64(month), standalone) + "月" + jaCardinal(int64(day), standalone) + "日"
+	},
+	time: func(hour, minute int, _ string) string {
+		res := jaCardinal(int64(hour), standalone) + "時"
+		if minute > 0 {
+			res += jaCardinal(int64(minute), standalone) + "分"
+		}
+		return res
+	},
+	dateOrder: "ymd", dateSep: "/",
+	units: map[string]noun{
+		"km": {forms: []string{"キロメートル"}}, "m": {forms: []string{"メートル"}}, "cm": {forms: []string{"センチメートル"}},
+		"mm": {forms: []string{"ミリメートル"}}, "kg": {forms: []string{"キログラム"}}, "g": {forms: []string{"グラム"}},
+		"mg": {forms: []string{"ミリグラム"}}, "l": {forms: []string{"リットル"}}, "ml": {forms: []string{"ミリリットル"}},
+		"km/h": {forms: []string{"キロメートル毎時"}}, "mph": {forms: []string{"マイル毎時"}}, "km²": {forms: []string{"平方キロメートル"}},
+		"m²": {forms: []string{"平方メートル"}}, "h": {forms: []string{"時間"}}, "min": {forms: []string{"分"}},
+		"°C": {forms: []string{"度"}}, "°F": {forms: []string{"度"}}, "°": {forms: []string{"度"}}, "%": {forms: []string{"パーセント"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"ユーロ"}}, noun{forms: []string{"セント"}}},
+		"$":  {noun{forms: []string{"ドル"}}, noun{forms: []string{"セント"}}},
+		"£":  {noun{forms: []string{"ポンド"}}, noun{forms: []string{"ペンス"}}},
+		"¥":  {noun{forms: []string{"円"}}, noun{}},
+		"₽":  {noun{forms: []string{"ルーブル"}}, noun{forms: []string{"コペイカ"}}},
+		"kn": {noun{forms: []string{"クーナ"}}, noun{forms: []string{"リパ"}}},
+	},
+}
+
+func jaBelow10000(n int64) string {
+	var res string
+	for _, place := range []struct {
+		value int64
+		name  string
+	}{{1000, "千"}, {100, "百"}, {10, "十"}} {
+		digit := n / place.value
+		n %= place.value
+		if digit > 1 {
+			res += jaDigits[digit]
+		}
+		if digit > 0 {
+			res += place.name
+		}
+	}
+	if n > 0 {
+		res += jaDigits[n]
+	}
+	return res
+}
+
+func jaCardinal(n int64, _ gender) string {
+	if n == 0 {
+		return jaDigits[0]
+	}
+	var res string
+	for _, s := range jaScales {
+		if n >= s.value {
+			res += jaBelow10000(n/s.value) + s.name.forms[0]
+			n %= s.value
+		}
+	}
+	return res + jaBelow10000(n)
+}
@@ -0,0 +1,403 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+)
+
+// lastComponent splits n into the part read as a cardinal and the last component, which is read as an ordinal in
+// Croatian and Russian: 1990 is 1900 and 90, 2024 is 2020 and 4, 2000 is 0 and 2000.
+func lastComponent(n int64) (int64, int64) {
+	var component int64
+	switch {
+	case n%100 == 0 && n%1000 != 0:
+		component = n % 1000
+	case n%1000 == 0 && n%1000000 != 0:
+		component = n % 1000000
+	case n%1000000 == 0:
+		component = n
+	case n%100 < 20 || n%10 == 0:
+		component = n % 100
+	default:
+		component = n % 10
+	}
+	return n - component, component
+}
+
+// Croatian
+
+var (
+	hrSmall = []string{"nula", "jedan", "dva", "tri", "četiri", "pet", "šest", "sedam", "osam", "devet", "deset",
+		"jedanaest", "dvanaest", "trinaest", "četrnaest", "petnaest", "šesnaest", "sedamnaest", "osamnaest", "devetnaest"}
+	hrTens     = []string{"", "", "dvadeset", "trideset", "četrdeset", "pedeset", "šezdeset", "sedamdeset", "osamdeset", "devedeset"}
+	hrHundreds = []string{"", "sto", "dvjesto", "tristo", "četiristo", "petsto", "šesto", "sedamsto", "osamsto", "devetsto"}
+	// Ordinal stems of 1-19, the tens and the hundreds
+	hrOrdinalSmall = []string{"", "prv", "drug", "treć", "četvrt", "pet", "šest", "sedm", "osm", "devet", "deset",
+		"jedanaest", "dvanaest", "trinaest", "četrnaest", "petnaest", "šesnaest", "sedamnaest", "osamnaest", "devetnaest"}
+	hrOrdinalHundreds = []string{"", "stot", "dvjestot", "tristot", "četiristot", "petstot", "šestot", "sedamstot", "osamstot", "devetstot"}
+	// Genitive, as in dates
+	hrMonths = []string{"siječnja", "veljače", "ožujka", "travnja", "svibnja", "lipnja", "srpnja", "kolovoza", "rujna",
+		"listopada", "studenoga", "prosinca"}
+	hrScales = []numberScale{
+		{1000000000, noun{forms: []string{"milijarda", "milijarde", "milijardi"}, feminine: true}},
+		{1000000, noun{forms: []string{"milijun", "milijuna", "milijuna"}}},
+		{1000, noun{forms: []string{"tisuća", "tisuće", "tisuća"}, feminine: true}},
+	}
+	// Ordinal endings of hard and soft (ending in ć) stems: nominative masculine, feminine and neuter, genitive
+	// masculine and feminine
+	hrEndings     = map[string]string{"m": "i", "f": "a", "n": "o", "gen": "og", "genf": "e"}
+	hrSoftEndings = map[string]string{"m": "i", "f": "a", "n": "e", "gen": "eg", "genf": "e"}
+	hrHour        = noun{forms: []string{"sat", "sata", "sati"}}
+)
+
+var croatian = numberLanguage{
+	thousands: `[.\x{a0}\x{202f}]`, decimal: ",", minus: "minus", to: "do", space: " ", and: "i",
+	cardinal: hrCardinal,
+	ordinal:  hrOrdinal,
+	decimalNumber: func(whole int64, frac string) string {
+		return hrCardinal(whole, standalone) + " zarez " + decimalDigits(frac, hrCardinal, " ")
+	},
+	plural: slavicPlural,
+	fraction: func(num, den int64) string {
+		prefix, _ := lastComponent(den)
+		if prefix > 0 || den > 100 {
+			return hrCardinal(num, standalone) + " kroz " + hrCardinal(den, standalone)
+		}
+		stem := hrOrdinalStem(den)
+		if den == 2 {
+			stem = "polov"
+		}
+		n := noun{forms: []string{stem + "ina", stem + "ine", stem + "ina"}, feminine: true}
+		return hrCardinal(num, feminine) + " " + n.forms[slavicPlural(num, false)]
+	},
+	date: func(day, month, year int, before string) string {
+		res := hrOrdinal(int64(day), "gen") + " " + hrMonths[month-1]
+		if year > 0 {
+			res += " " + hrOrdinal(int64(year), "genf")
+		}
+		return res
+	},
+	time: func(hour, minute int, _ string) string {
+		if minute == 0 {
+			return hrCardinal(int64(hour), masculine) + " " + hrHour.forms[slavicPlural(int64(hour), false)]
+		}
+		return hrCardinal(int64(hour), masculine) + " i " + hrCardinal(int64(minute), standalone)
+	},
+	dateOrder: "dmy", dateSep: `\.`,
+	months: hrMonths,
+	monthDates: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?P<day>\d{1,2})\.\s*` + monthsPattern(hrMonths) + `(?:\s+(?P<year>\d{4})\.?)?`),
+	},
+	// 3. mjesto, the following word is kept
+	ordinals:    regexp.MustCompile(`(\d+)(\.)(\s+\p{L}+)`),
+	ordinalForm: func(suffix, before, after string) string { return "m" },
+	units: map[string]noun{
+		"km": {forms: []string{"kilometar", "kilometra", "kilometara"}}, "m": {forms: []string{"metar", "metra", "metara"}},
+		"cm": {forms: []string{"centimetar", "centimetra", "centimetara"}}, "mm": {forms: []string{"milimetar", "milimetra", "milimetara"}},
+		"kg": {forms: []string{"kilogram", "kilograma", "kilograma"}}, "g": {forms: []string{"gram", "grama", "grama"}},
+		"mg": {forms: []string{"miligram", "miligrama", "miligrama"}}, "l": {forms: []string{"litra", "litre", "litara"}, feminine: true},
+		"ml":   {forms: []string{"mililitar", "mililitra", "mililitara"}},
+		"km/h": {forms: []string{"kilometar na sat", "kilometra na sat", "kilometara na sat"}},
+		"mph":  {forms: []string{"milja na sat", "milje na sat", "milja na sat"}, feminine: true},
+		"km²":  {forms: []string{"četvorni kilometar", "četvorna kilometra", "četvornih kilometara"}},
+		"m²":   {forms: []string{"četvorni metar", "četvorna metra", "četvornih metara"}},
+		"h":    hrHour, "min": {forms: []string{"minuta", "minute", "minuta"}, feminine: true},
+		"°C": {forms: []string{"stupanj Celzija", "stupnja Celzija", "stupnjeva Celzija"}},
+		"°F": {forms: []string{"stupanj Fahrenheita", "stupnja Fahrenheita", "stupnjeva Fahrenheita"}},
+		"°":  {forms: []string{"stupanj", "stupnja", "stupnjeva"}}, "%": {forms: []string{"posto"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"euro", "eura", "eura"}}, noun{forms: []string{"cent", "centa", "centi"}}},
+		"$":  {noun{forms: []string{"dolar", "dolara", "dolara"}}, noun{forms: []string{"cent", "centa", "centi"}}},
+		"£":  {noun{forms: []string{"funta", "funte", "funti"}, feminine: true}, noun{forms: []string{"peni", "penija", "penija"}}},
+		"¥":  {noun{forms: []string{"jen", "jena", "jena"}}, noun{}},
+		"₽":  {noun{forms: []string{"rubalj", "rublja", "rubalja"}}, noun{forms: []string{"kopejka", "kopejke", "kopejki"}, feminine: true}},
+		"kn": {noun{forms: []string{"kuna", "kune", "kuna"}, feminine: true}, noun{forms: []string{"lipa", "lipe", "lipa"}, feminine: true}},
+	},
+}
+
+func hrBelow1000(n int64, g gender) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, hrHundreds[n/100])
+	}
+	rest := n % 100
+	if rest >= 20 {
+		parts = append(parts, hrTens[rest/10])
+		rest %= 10
+	}
+	if rest > 0 || len(parts) == 0 {
+		word := hrSmall[rest]
+		switch {
+		case g == feminine && rest == 1:
+			word = "jedna"
+		case g == feminine && rest == 2:
+			word = "dvije"
+		}
+		parts = append(parts, word)
+	}
+	return strings.Join(parts, " ")
+}
+
+func hrCardinal(n int64, g gender) string {
+	var parts []string
+	for _, s := range hrScales {
+		if n < s.value {
+			continue
+		}
+		count := n / s.value
+		n %= s.value
+		switch {
+		case count == 1 && s.value == 1000:
+			parts = append(parts, "tisuću")
+		case count == 1:
+			parts = append(parts, s.name.forms[0])
+		default:
+			parts = append(parts, hrBelow1000(count, s.name.gender())+" "+s.name.forms[slavicPlural(count, false)])
+		}
+	}
+	if n > 0 || len(parts) == 0 {
+		parts = append(parts, hrBelow1000(n, g))
+	}
+	return strings.Join(parts, " ")
+}
+
+// hrOrdinalStem is the ordinal of the last component (see lastComponent) without the ending.
+func hrOrdinalStem(component int64) string {
+	switch {
+	case component < 20:
+		return hrOrdinalSmall[component]
+	case component < 100:
+		return hrTens[component/10]
+	case component < 1000:
+		return hrOrdinalHundreds[component/100]
+	case component == 1000:
+		return "tisuć"
+	case component < 1000000:
+		return strings.Replace(hrCardinal(component/1000, feminine), " ", "", -1) + "tisuć"
+	}
+	if component == 1000000 {
+		return "milijunt"
+	}
+	return strings.Replace(hrCardinal(component/1000000, standalone), " ", "", -1) + "milijunt"
+}
+
+func hrOrdinal(n int64, form string) string {
+	prefix, component := lastComponent(n)
+	stem := hrOrdinalStem(component)
+	endings := hrEndings
+	if strings.HasSuffix(stem, "ć") {
+		endings = hrSoftEndings
+	}
+	res := stem + endings[form]
+	if prefix > 0 {
+		res = hrCardinal(prefix, standalone) + " " + res
+	}
+	return res
+}
+
+// Russian
+
+var (
+	ruSmall = []string{"ноль", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять", "десять",
+		"одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать", "шестнадцать", "семнадцать",
+		"восемнадцать", "девятнадцать"}
+	ruTens     = []string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят", "шестьдесят", "семьдесят", "восемьдесят", "девяносто"}
+	ruHundreds = []string{"", "сто", "двести", "триста", "четыреста", "пятьсот", "шестьсот", "семьсот", "восемьсот", "девятьсот"}
+	// Ordinal stems of 1-19, the tens and the hundreds
+	ruOrdinalSmall = []string{"", "перв", "втор", "трет", "четвёрт", "пят", "шест", "седьм", "восьм", "девят", "десят",
+		"одиннадцат", "двенадцат", "тринадцат", "четырнадцат", "пятнадцат", "шестнадцат", "семнадцат", "восемнадцат",
+		"девятнадцат"}
+	ruOrdinalTens     = []string{"", "", "двадцат", "тридцат", "сороков", "пятидесят", "шестидесят", "семидесят", "восьмидесят", "девяност"}
+	ruOrdinalHundreds = []string{"", "сот", "двухсот", "трёхсот", "четырёхсот", "пятисот", "шестисот", "семисот", "восьмисот", "девятисот"}
+	// Genitive prefixes of the thousands: двухтысячный
+	ruThousands = []string{"", "", "двух", "трёх", "четырёх", "пяти", "шести", "семи", "восьми", "девяти"}
+	// Stems with a stressed ending (второй) and третий
+	ruStressed = map[string]bool{"втор": true, "шест": true, "седьм": true, "восьм": true, "сороков": true}
+	// Genitive, as in dates
+	ruMonths = []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября",
+		"ноября", "декабря"}
+	ruScales = []numberScale{
+		{1000000000, noun{forms: []string{"миллиард", "миллиарда", "миллиардов"}}},
+		{1000000, noun{forms: []string{"миллион", "миллиона", "миллионов"}}},
+		{1000, noun{forms: []string{"тысяча", "тысячи", "тысяч"}, feminine: true}},
+	}
+	// Ordinal endings: nominative masculine, feminine, neuter and plural, genitive, dative, prepositional and genitive
+	// plural
+	ruEndings         = map[string]string{"m": "ый", "f": "ая", "n": "ое", "pl": "ые", "gen": "ого", "dat": "ому", "prep": "ом", "genpl": "ых"}
+	ruStressedEndings = map[string]string{"m": "ой", "f": "ая", "n": "ое", "pl": "ые", "gen": "ого", "dat": "ому", "prep": "ом", "genpl": "ых"}
+	ruThirdEndings    = map[string]string{"m": "ий", "f": "ья", "n": "ье", "pl": "ьи", "gen": "ьего", "dat": "ьему", "prep": "ьем", "genpl": "ьих"}
+	// Forms of the ordinal suffixes: 5-й, 5-го
+	ruOrdinalSuffixes = map[string]string{"й": "m", "ый": "m", "ий": "m", "ой": "m", "я": "f", "ая": "f", "ья": "f",
+		"е": "n", "ое": "n", "ье": "n", "ые": "pl", "го": "gen", "ого": "gen", "му": "dat", "ому": "dat", "м": "prep",
+		"ом": "prep", "х": "genpl", "ых": "genpl"}
+	ruGenitiveWords = map[string]bool{"до": true, "с": true, "со": true, "от": true, "после": true, "около": true, "из": true}
+	ruHour          = noun{forms: []string{"час", "часа", "часов"}}
+	ruMinute        = noun{forms: []string{"минута", "минуты", "минут"}, feminine: true}
+)
+
+var russian = numberLanguage{
+	thousands: `[ \x{a0}\x{202f}]`, decimal: ",", minus: "минус", to: "до", space: " ",
+	cardinal: ruCardinal,
+	ordinal:  ruOrdinal,
+	decimalNumber: func(whole int64, frac string) string {
+		if len(frac) > 3 {
+			return ruCardinal(whole, standalone) + " запятая " + digitByDigit(frac, ruCardinal, " ")
+		}
+		// три целых пять десятых
+		var num, den int64 = 0, 1
+		for _, r := range frac {
+			num = num*10 + int64(r-'0')
+			den *= 10
+		}
+		wholeWord := "целых"
+		if slavicPlural(whole, false) == 0 {
+			wholeWord = "целая"
+		}
+		return ruCardinal(whole, feminine) + " " + wholeWord + " " + ruFraction(num, den)
+	},
+	plural:   slavicPlural,
+	fraction: ruFraction,
+	date: func(day, month, year int, before string) string {
+		form := "n"
+		if ruGenitiveWords[before] {
+			form = "gen"
+		}
+		res := ruOrdinal(int64(day), form) + " " + ruMonths[month-1]
+		if year > 0 {
+			res += " " + ruOrdinal(int64(year), "gen") + " года"
+		}
+		return res
+	},
+	time: func(hour, minute int, _ string) string {
+		res := ruCardinal(int64(hour), masculine) + " " + ruHour.forms[slavicPlural(int64(hour), false)]
+		if minute > 0 {
+			res += " " + ruCardinal(int64(minute), feminine) + " " + ruMinute.forms[slavicPlural(int64(minute), false)]
+		}
+		return res
+	},
+	dateOrder: "dmy", dateSep: `\.`,
+	months: ruMonths,
+	monthDates: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?P<day>\d{1,2})\s+` + monthsPattern(ruMonths) + `(?:\s+(?P<year>\d{4})(?:\s*г\.|\s+года)?)?`),
+	},
+	ordinals: regexp.MustCompile(`(\d+)-(ого|ому|ый|ий|ой|ая|ья|ое|ье|ые|ых|ом|го|му|й|я|е|м|х)`),
+	ordinalForm: func(suffix, before, after string) string {
+		return ruOrdinalSuffixes[suffix]
+	},
+	units: map[string]noun{
+		"km": {forms: []string{"километр", "километра", "километров"}}, "m": {forms: []string{"метр", "метра", "метров"}},
+		"cm": {forms: []string{"сантиметр", "сантиметра", "сантиметров"}}, "mm": {forms: []string{"миллиметр", "миллиметра", "миллиметров"}},
+		"kg": {forms: []string{"килограмм", "килограмма", "килограммов"}}, "g": {forms: []string{"грамм", "грамма", "граммов"}},
+		"mg": {forms: []string{"миллиграмм", "миллиграмма", "миллиграммов"}}, "l": {forms: []string{"литр", "литра", "литров"}},
+		"ml":   {forms: []string{"миллилитр", "миллилитра", "миллилитров"}},
+		"km/h": {forms: []string{"километр в час", "километра в час", "километров в час"}},
+		"mph":  {forms: []string{"миля в час", "мили в час", "миль в час"}, feminine: true},
+		"km²":  {forms: []string{"квадратный километр", "квадратных километра", "квадратных километров"}},
+		"m²":   {forms: []string{"квадратный метр", "квадратных метра", "квадратных метров"}},
+		"h":    ruHour, "min": ruMinute,
+		"°C": {forms: []string{"градус Цельсия", "градуса Цельсия", "градусов Цельсия"}},
+		"°F": {forms: []string{"градус Фаренгейта", "градуса Фаренгейта", "градусов Фаренгейта"}},
+		"°":  {forms: []string{"градус", "градуса", "градусов"}}, "%": {forms: []string{"процент", "процента", "процентов"}},
+	},
+	currencies: map[string]currency{
+		"€":  {noun{forms: []string{"евро"}}, noun{forms: []string{"цент", "цента", "центов"}}},
+		"$":  {noun{forms: []string{"доллар", "доллара", "долларов"}}, noun{forms: []string{"цент", "цента", "центов"}}},
+		"£":  {noun{forms: []string{"фунт", "фунта", "фунтов"}}, noun{forms: []string{"пенни"}}},
+		"¥":  {noun{forms: []string{"иена", "иены", "иен"}, feminine: true}, noun{}},
+		"₽":  {noun{forms: []string{"рубль", "рубля", "рублей"}}, noun{forms: []string{"копейка", "копейки", "копеек"}, feminine: true}},
+		"kn": {noun{forms: []string{"куна", "куны", "кун"}, feminine: true}, noun{forms: []string{"липа", "липы", "лип"}, feminine: true}},
+	},
+}
+
+func ruBelow1000(n int64, g gender) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, ruHundreds[n/100])
+	}
+	rest := n % 100
+	if rest >= 20 {
+		parts = append(parts, ruTens[rest/10])
+		rest %= 10
+	}
+	if rest > 0 || len(parts) == 0 {
+		word := ruSmall[rest]
+		switch {
+		case g == feminine && rest == 1:
+			word = "одна"
+		case g == feminine && rest == 2:
+			word = "две"
+		}
+		parts = append(parts, word)
+	}
+	return strings.Join(parts, " ")
+}
+
+func ruCardinal(n int64, g gender) string {
+	var parts []string
+	for _, s := range ruScales {
+		if n < s.value {
+			continue
+		}
+		count := n / s.value
+		n %= s.value
+		if count == 1 {
+			parts = append(parts, s.name.forms[0])
+		} else {
+			parts = append(parts, ruBelow1000(count, s.name.gender())+" "+s.name.forms[slavicPlural(count, false)])
+		}
+	}
+	if n > 0 || len(parts) == 0 {
+		parts = append(parts, ruBelow1000(n, g))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ruOrdinalStem is the ordinal of the last component (see lastComponent) without the ending.
+func ruOrdinalStem(component int64) string {
+	switch {
+	case component < 20:
+		return ruOrdinalSmall[component]
+	case component < 100:
+		return ruOrdinalTens[component/10]
+	case component < 1000:
+		return ruOrdinalHundreds[component/100]
+	case component < 10000:
+		return ruThousands[component/1000] + "тысячн"
+	case component < 1000000:
+		return strings.Replace(ruCardinal(component/1000, standalone), " ", "", -1) + "тысячн"
+	}
+	if component == 1000000 {
+		return "миллионн"
+	}
+	return strings.Replace(ruCardinal(component/1000000, standalone), " ", "", -1) + "миллионн"
+}
+
+func ruOrdinal(n int64, form string) string {
+	if form == "" {
+		form = "m"
+	}
+	prefix, component := lastComponent(n)
+	stem := ruOrdinalStem(component)
+	endings := ruEndings
+	switch {
+	case stem == "трет":
+		endings = ruThirdEndings
+	case ruStressed[stem]:
+		endings = ruStressedEndings
+	}
+	res := stem + endings[form]
+	if prefix > 0 {
+		res = ruCardinal(prefix, standalone) + " " + res
+	}
+	return res
+}
+
+// ruFraction reads the numerator as a feminine cardinal and the denominator as an ordinal: одна пятая, две пятых.
+func ruFraction(num, den int64) string {
+	form := "genpl"
+	if slavicPlural(num, false) == 0 {
+		form = "f"
+	}
+	return ruCardinal(num, feminine) + " " + ruOrdinal(den, form)
+}
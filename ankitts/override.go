@@ -0,0 +1,27 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+)
+
+var brRegexp = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+var ssmlElementRegexp = regexp.MustCompile(`(?i)<(phoneme|sub|break|say-as|prosody|emphasis|lang|voice|s|p)[\s/>]`)
+
+// OverrideText is the text to synthesize from an override field. The field is used as it is except for [sound:] tags,
+// HTML from Anki's editor and escaped SSML (typed into the editor as text) are converted. Text without SSML elements
+// stays escaped, so that &, < and > are read as text.
+func OverrideText(field string) string {
+	text := strings.TrimSpace(soundTagRegexp.ReplaceAllString(field, ""))
+	if ssmlElementRegexp.MatchString(text) {
+		// Entered in the HTML editor
+		return text
+	}
+	text = htmlToText(brRegexp.ReplaceAllString(text, " "))
+	text = strings.TrimSpace(whitespaceRegexp.ReplaceAllString(text, " "))
+	if ssmlElementRegexp.MatchString(text) {
+		return text
+	}
+	return ssmlTextEscaper.Replace(text)
+}
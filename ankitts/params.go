@@ -1,10 +1,266 @@
 package ankitts
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
 type Config struct {
+	// Prefer the environment, the secrets file or the keyring, see ResolveSpeechApiKey
 	SpeechApiKey string `json:"speech_bing_api_key"`
+	SecretsFile  string `json:"secrets_file"`
+	// Defaults for all jobs
+	Defaults Params `json:"defaults"`
+	// Per Anki profile defaults, override Defaults
+	Profiles map[string]Params `json:"profiles"`
+	Jobs     []Params          `json:"jobs"`
+	// Maximum characters per engine and calendar month, runs stop before exceeding them
+	MonthlyCaps map[string]int `json:"monthly_caps"`
+	// Usage ledger, default ~/.anki-tts-usage.json
+	LedgerFile string `json:"ledger_file"`
+	// Pronunciation lexicon files by locale (de-DE) or language (de)
+	Lexicons map[string]string `json:"lexicons"`
+}
+
+// LexiconFile returns the lexicon file for the locale, or for its language if there is none for the locale.
+func (c Config) LexiconFile(locale string) string {
+	for key, filename := range c.Lexicons {
+		if strings.EqualFold(key, locale) {
+			return filename
+		}
+	}
+	language := strings.SplitN(locale, "-", 2)[0]
+	for key, filename := range c.Lexicons {
+		if strings.EqualFold(key, language) {
+			return filename
+		}
+	}
+	return ""
+}
+
+// String and GoString hide the API key, so that it can't end up in logs.
+func (c Config) String() string {
+	return c.GoString()
+}
+
+func (c Config) GoString() string {
+	if c.SpeechApiKey != "" {
+		c.SpeechApiKey = "***"
+	}
+	type config Config
+	return fmt.Sprintf("%#v", config(c))
+}
+
+// Job finds a job by name.
+func (c Config) Job(name string) (Params, error) {
+	var names []string
+	for _, job := range c.Jobs {
+		if job.Name == name {
+			return job, nil
+		}
+		names = append(names, job.Name)
+	}
+	return Params{}, fmt.Errorf("job %s not found, available jobs: %s", name, strings.Join(names, ", "))
+}
+
+// Layered merges (in order of increasing priority): built in defaults, config defaults, config profile section, the
+// job, environment variables and command line flags.
+func (c Config) Layered(job, env, flags Params) Params {
+	res := DefaultParams.Merge(c.Defaults).Merge(job).Merge(env).Merge(flags)
+	if profile, found := c.Profiles[res.Profile]; found && res.Profile != "" {
+		res = DefaultParams.Merge(c.Defaults).Merge(profile).Merge(job).Merge(env).Merge(flags)
+	}
+	return res
+}
+
+type TextRule struct {
+	Regexp  string `json:"regexp"`
+	Replace string `json:"replace"`
 }
 
+// Params are the settings of one job, they can be set in the config file, environment variables (ANKI_TTS_ + the
+// upper cased JSON name, for example ANKI_TTS_DECK) or command line flags.
 type Params struct {
-	CollectionDir, CardType, DeckName, LanguageLocale, SpeechColumnsStr string
+	Name             string     `json:"name,omitempty"`
+	CollectionDir    string     `json:"collection_dir,omitempty"`
+	Profile          string     `json:"profile,omitempty"`
+	Query            string     `json:"query,omitempty"`
+	DeckName         string     `json:"deck,omitempty"`
+	NoSubdecks       bool       `json:"no_subdecks,omitempty"`
+	CardType         string     `json:"note_type,omitempty"`
+	CardStates       string     `json:"card_states,omitempty"`
+	DueWithin        string     `json:"due_within,omitempty"`
+	SpeechColumnsStr string     `json:"fields,omitempty"`
+	LanguageLocale   string     `json:"locale,omitempty"`
+	Voice            string     `json:"voice,omitempty"`
+	Engine           string     `json:"engine,omitempty"`
+	TargetField      string     `json:"target_field,omitempty"`
+	BudgetChars      int        `json:"budget_chars,omitempty"`
+	Format           string     `json:"format,omitempty"`
+	Naming           string     `json:"naming,omitempty"`
+	SlugLength       int        `json:"slug_length,omitempty"`
+	Transliterate    bool       `json:"transliterate,omitempty"`
+	Furigana         bool       `json:"furigana,omitempty"`
+	ReadingField     string     `json:"reading_field,omitempty"`
+	OverrideField    string     `json:"override_field,omitempty"`
+	ClozeTarget      string     `json:"cloze_target,omitempty"`
+	ClozeBlank       string     `json:"cloze_blank,omitempty"`
+	LexiconSSML      bool       `json:"lexicon_ssml,omitempty"`
+	TextRules        []TextRule `json:"text_rules,omitempty"`
+	Pipeline         []TextStep `json:"pipeline,omitempty"`
+	// Regexp for inline language markers, with the language in the first and the text in the second group
+	LanguageMarker string `json:"language_marker,omitempty"`
+	// Locale and voice by language (en) or locale (en-GB) of text marked as another language
+	Languages map[string]LanguageVoice `json:"languages,omitempty"`
+	// What to do with text in scripts the locale's voices can't read, see ScriptMismatchModes
+	ScriptMismatch string `json:"script_mismatch,omitempty"`
+	FallbackLocale string `json:"fallback_locale,omitempty"`
+	// JSON names of the values that were set (in the config file, the environment or by flags), see Merge
+	set map[string]bool
+}
+
+// UnmarshalJSON marks the values in the JSON object as set, so that "transliterate": false in a job turns off
+// transliterate from the defaults.
+func (p *Params) UnmarshalJSON(data []byte) error {
+	type params Params
+	if err := json.Unmarshal(data, (*params)(p)); err != nil {
+		return err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	for name := range values {
+		p.MarkSet(name)
+	}
+	return nil
+}
+
+// MarkSet marks the value with the JSON name as set, Merge copies it even if it's empty.
+func (p *Params) MarkSet(name string) {
+	if p.set == nil {
+		p.set = map[string]bool{}
+	}
+	p.set[name] = true
+}
+
+// LanguageVoice is the locale and voice used for text in another language, see Params.Languages.
+type LanguageVoice struct {
+	Locale string `json:"locale,omitempty"`
+	Voice  string `json:"voice,omitempty"`
+}
+
+var DefaultParams = Params{
+	SpeechColumnsStr: "Back",
+	CardStates:       "active",
+	Engine:           "bing",
+}
+
+// Merge returns a copy of p with the values from other that are not empty or were set (see MarkSet), so that
+// -transliterate=false turns off transliterate from p.
+func (p Params) Merge(other Params) Params {
+	res := p
+	resValue := reflect.ValueOf(&res).Elem()
+	otherValue := reflect.ValueOf(other)
+	for n := 0; n < otherValue.NumField(); n++ {
+		field := otherValue.Type().Field(n)
+		if field.PkgPath != "" {
+			continue
+		}
+		if !otherValue.Field(n).IsZero() || other.set[jsonName(field)] {
+			resValue.Field(n).Set(otherValue.Field(n))
+		}
+	}
+	return res
+}
+
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+// AudioFormat is the audio file format and extension: mp3 (default), wav or ogg (if supported by the engine).
+func (p Params) AudioFormat() string {
+	if p.Format == "" {
+		return "mp3"
+	}
+	return strings.ToLower(p.Format)
+}
+
+// TextSteps are the text preparation steps: text_rules as regex steps followed by the pipeline, or the default
+// preparation (see DefaultPipeline) if no pipeline is configured.
+func (p Params) TextSteps() []TextStep {
+	if len(p.Pipeline) == 0 {
+		return DefaultPipeline(p.TextRules)
+	}
+	return append(ruleSteps(p.TextRules), p.Pipeline...)
+}
+
+// SegmentVoice returns the locale and voice for a language segment (see SplitLanguages): the job's locale and voice for
+// its own language, the entry in languages for the locale or the language, or the language's default locale (see
+// DefaultLocale).
+func (p Params) SegmentVoice(language string) (string, string) {
+	languageOf := func(locale string) string { return strings.ToLower(strings.SplitN(locale, "-", 2)[0]) }
+	if language == "" || strings.EqualFold(language, p.LanguageLocale) ||
+		(!strings.Contains(language, "-") && languageOf(language) == languageOf(p.LanguageLocale)) {
+		return p.LanguageLocale, p.Voice
+	}
+	lv, found := LanguageVoice{}, false
+	for _, key := range []string{language, languageOf(language)} {
+		for k, v := range p.Languages {
+			if !found && strings.EqualFold(k, key) {
+				lv, found = v, true
+			}
+		}
+	}
+	if lv.Locale == "" {
+		if strings.Contains(language, "-") {
+			lv.Locale = language
+		} else {
+			lv.Locale = DefaultLocale(language)
+		}
+	}
+	return lv.Locale, lv.Voice
 }
 
+// ParamsFromEnv returns the params set by environment variables: ANKI_TTS_ + the upper cased JSON name. Switches are
+// true or false, lists (like ANKI_TTS_TEXT_RULES) are JSON.
+func ParamsFromEnv() (Params, error) {
+	var res Params
+	resValue := reflect.ValueOf(&res).Elem()
+	for n := 0; n < resValue.NumField(); n++ {
+		field := resValue.Type().Field(n)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonName(field)
+		envName := "ANKI_TTS_" + strings.ToUpper(name)
+		value := os.Getenv(envName)
+		if value == "" {
+			continue
+		}
+		var err error
+		switch field.Type.Kind() {
+		case reflect.String:
+			resValue.Field(n).SetString(value)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(value)
+			resValue.Field(n).SetBool(b)
+		case reflect.Int:
+			var i int
+			i, err = strconv.Atoi(value)
+			resValue.Field(n).SetInt(int64(i))
+		default:
+			err = json.Unmarshal([]byte(value), resValue.Field(n).Addr().Interface())
+		}
+		if err != nil {
+			return Params{}, fmt.Errorf("invalid %s=%s: %v", envName, value, err)
+		}
+		res.MarkSet(name)
+	}
+	return res, nil
+}
@@ -0,0 +1,56 @@
+package ankitts
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLayered(t *testing.T) {
+	var c Config
+	err := json.Unmarshal([]byte(`{
+		"defaults": {"transliterate": true, "furigana": true, "budget_chars": 100, "deck": "German"},
+		"jobs": [{"name": "kanji", "furigana": false}]
+	}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags Params
+	flags.MarkSet("budget_chars")
+	flags.MarkSet("transliterate")
+	res := c.Layered(c.Jobs[0], Params{}, flags)
+	if res.Transliterate || res.Furigana || res.BudgetChars != 0 || res.DeckName != "German" || res.Name != "kanji" {
+		t.Errorf("expected switches and the budget turned off, got %#v", res)
+	}
+}
+
+func TestParamsFromEnv(t *testing.T) {
+	t.Setenv("ANKI_TTS_DECK", "German")
+	t.Setenv("ANKI_TTS_BUDGET_CHARS", "5000")
+	t.Setenv("ANKI_TTS_TRANSLITERATE", "false")
+	t.Setenv("ANKI_TTS_TEXT_RULES", `[{"regexp": "e\\.g\\.", "replace": "for example"}]`)
+	env, err := ParamsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.DeckName != "German" || env.BudgetChars != 5000 ||
+		!reflect.DeepEqual(env.TextRules, []TextRule{{Regexp: `e\.g\.`, Replace: "for example"}}) {
+		t.Errorf("unexpected params %#v", env)
+	}
+	if res := (Params{Transliterate: true}).Merge(env); res.Transliterate {
+		t.Error("ANKI_TTS_TRANSLITERATE=false didn't turn off transliterate")
+	}
+
+	for name, value := range map[string]string{
+		"ANKI_TTS_BUDGET_CHARS":  "lots",
+		"ANKI_TTS_TRANSLITERATE": "ja",
+		"ANKI_TTS_PIPELINE":      "numbers",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := ParamsFromEnv(); err == nil {
+				t.Errorf("expected an error for %s=%s", name, value)
+			}
+		})
+	}
+}
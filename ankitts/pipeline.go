@@ -0,0 +1,198 @@
+package ankitts
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TextStep is one step of the text preparation pipeline:
+//
+//	{"step": "html"}                                        HTML to text
+//	{"step": "regex", "regexp": "\\(.*?\\)", "replace": ""}  regular expression replacement
+//	{"step": "remove-parens"}                               remove text in parentheses
+//	{"step": "strip-articles", "words": ["der", "die"]}     remove a leading article (default: the locale's articles)
+//	{"step": "numbers"}                                     spell out numbers, dates, times, currency and units
+//	{"step": "keep-charset", "chars": ".,!?'-"}             keep only letters, digits and these characters
+//	{"step": "whitespace"}                                  collapse and trim whitespace
+type TextStep struct {
+	Step    string   `json:"step"`
+	Regexp  string   `json:"regexp,omitempty"`
+	Replace string   `json:"replace,omitempty"`
+	Words   []string `json:"words,omitempty"`
+	Chars   string   `json:"chars,omitempty"`
+}
+
+func (ts TextStep) String() string {
+	switch ts.Step {
+	case "regex":
+		return fmt.Sprintf("regex %s -> %q", ts.Regexp, ts.Replace)
+	case "keep-charset":
+		if ts.Chars == "" {
+			return fmt.Sprintf("keep-charset %q", defaultKeptChars)
+		}
+		return fmt.Sprintf("keep-charset %q", ts.Chars)
+	case "strip-articles":
+		if len(ts.Words) > 0 {
+			return "strip-articles " + strings.Join(ts.Words, ",")
+		}
+	}
+	return ts.Step
+}
+
+// Characters kept by keep-charset if none are configured
+const defaultKeptChars = ".,!?"
+
+var defaultArticles = map[string][]string{
+	"de": {"der", "die", "das", "den", "dem", "des", "ein", "eine", "einen", "einem", "einer", "eines"},
+	"en": {"the", "a", "an"},
+	"fr": {"le", "la", "les", "l'", "un", "une", "des"},
+	"es": {"el", "la", "los", "las", "un", "una", "unos", "unas"},
+	"it": {"il", "lo", "la", "i", "gli", "le", "l'", "un", "uno", "una"},
+	"pt": {"o", "a", "os", "as", "um", "uma"},
+	"nl": {"de", "het", "een"},
+}
+
+// DefaultPipeline is the preparation of older versions: the text rules, then removing [...], HTML and all characters
+// except letters, digits and .,!?
+func DefaultPipeline(rules []TextRule) []TextStep {
+	return append(ruleSteps(rules),
+		TextStep{Step: "regex", Regexp: `\[.*?\]`},
+		TextStep{Step: "html"},
+		TextStep{Step: "keep-charset"},
+		TextStep{Step: "whitespace"},
+	)
+}
+
+// ruleSteps converts text_rules to regex steps.
+func ruleSteps(rules []TextRule) []TextStep {
+	var res []TextStep
+	for _, rule := range rules {
+		res = append(res, TextStep{Step: "regex", Regexp: rule.Regexp, Replace: rule.Replace})
+	}
+	return res
+}
+
+type pipelineStep struct {
+	TextStep
+	fn func(string) string
+}
+
+// Pipeline prepares field text for the TTS engine.
+type Pipeline struct {
+	steps []pipelineStep
+}
+
+// NewPipeline compiles the steps, the locale selects the default articles of strip-articles.
+func NewPipeline(steps []TextStep, locale string) (*Pipeline, error) {
+	res := &Pipeline{}
+	for _, step := range steps {
+		fn, err := compileStep(step, locale)
+		if err != nil {
+			return nil, err
+		}
+		res.steps = append(res.steps, pipelineStep{TextStep: step, fn: fn})
+	}
+	return res, nil
+}
+
+var (
+	parensRegexp     = regexp.MustCompile(`\([^()]*\)`)
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+)
+
+func compileStep(step TextStep, locale string) (func(string) string, error) {
+	switch step.Step {
+	case "html":
+		return htmlToText, nil
+	case "regex":
+		re, err := regexp.Compile(step.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %s: %s", step.Regexp, err.Error())
+		}
+		return func(str string) string { return re.ReplaceAllString(str, step.Replace) }, nil
+	case "remove-parens":
+		return func(str string) string {
+			// Nested parentheses from the inside out
+			for parensRegexp.MatchString(str) {
+				str = parensRegexp.ReplaceAllString(str, "")
+			}
+			return str
+		}, nil
+	case "strip-articles":
+		words := step.Words
+		if len(words) == 0 {
+			words = defaultArticles[strings.ToLower(strings.SplitN(locale, "-", 2)[0])]
+		}
+		if len(words) == 0 {
+			return func(str string) string { return str }, nil
+		}
+		var alternatives []string
+		for _, w := range words {
+			if strings.HasSuffix(w, "'") {
+				alternatives = append(alternatives, regexp.QuoteMeta(w))
+			} else {
+				alternatives = append(alternatives, regexp.QuoteMeta(w)+`\s+`)
+			}
+		}
+		re := regexp.MustCompile(`(?i)^\s*(` + strings.Join(alternatives, "|") + `)`)
+		return func(str string) string { return re.ReplaceAllString(str, "") }, nil
+	case "keep-charset":
+		chars := step.Chars
+		if chars == "" {
+			chars = defaultKeptChars
+		}
+		return func(str string) string {
+			return strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || strings.ContainsRune(chars, r) {
+					return r
+				}
+				return ' '
+			}, str)
+		}, nil
+	case "numbers":
+		return func(str string) string { return ExpandNumbers(str, locale) }, nil
+	case "whitespace":
+		return func(str string) string { return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(str, " ")) }, nil
+	}
+	return nil, fmt.Errorf("unknown text step %s", step.Step)
+}
+
+func htmlToText(str string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(str))
+	if err != nil {
+		return str
+	}
+	return doc.Text()
+}
+
+// Add appends a step which isn't configurable in TextStep, like the lexicon.
+func (p *Pipeline) Add(name string, fn func(string) string) {
+	p.steps = append(p.steps, pipelineStep{TextStep: TextStep{Step: name}, fn: fn})
+}
+
+func (p Pipeline) Apply(text string) string {
+	for _, step := range p.steps {
+		text = step.fn(text)
+	}
+	return text
+}
+
+// StepResult is the text after a step, see Trace.
+type StepResult struct {
+	Step string `json:"step"`
+	Text string `json:"text"`
+}
+
+// Trace applies the pipeline and returns the text after every step.
+func (p Pipeline) Trace(text string) []StepResult {
+	var res []StepResult
+	for _, step := range p.steps {
+		text = step.fn(text)
+		res = append(res, StepResult{Step: step.String(), Text: text})
+	}
+	return res
+}
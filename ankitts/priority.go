@@ -0,0 +1,80 @@
+package ankitts
+
+import (
+	"time"
+
+	"github.com/tkrajina/anki"
+)
+
+// Priority is how useful audio for a note is right now, notes are processed in the order of Before when the
+// character budget is limited.
+type Priority struct {
+	// Days until the soonest due card (negative if overdue), nil if no card has a due date (new cards)
+	DueDay *int
+	// Lapses of all cards plus recently failed reviews, leeches have many
+	Lapses int
+	// The note ID is the creation time, newer notes first
+	NoteID anki.ID
+}
+
+// NotePriority computes the priority from the (selected) cards of a note and the recent failures per card, see
+// RecentFailures.
+func NotePriority(noteID anki.ID, cards []anki.Card, failures map[anki.ID]int, now time.Time) Priority {
+	res := Priority{NoteID: noteID}
+	for _, card := range cards {
+		res.Lapses += card.Lapses + failures[card.ID]
+		if card.Due == nil {
+			continue
+		}
+		day := int(time.Time(*card.Due).Sub(now).Hours() / 24)
+		if time.Time(*card.Due).Before(now) {
+			day--
+		}
+		if res.DueDay == nil || day < *res.DueDay {
+			res.DueDay = &day
+		}
+	}
+	return res
+}
+
+// Before orders by the soonest due day, then by lapses and then newest notes first.
+func (p Priority) Before(other Priority) bool {
+	if (p.DueDay == nil) != (other.DueDay == nil) {
+		return p.DueDay != nil
+	}
+	if p.DueDay != nil && *p.DueDay != *other.DueDay {
+		return *p.DueDay < *other.DueDay
+	}
+	if p.Lapses != other.Lapses {
+		return p.Lapses > other.Lapses
+	}
+	return p.NoteID > other.NoteID
+}
+
+// RecentFailures counts the reviews answered with "again" since the given time, per card.
+func RecentFailures(db *anki.DB, since time.Time) (map[anki.ID]int, error) {
+	reviews, err := db.Reviews()
+	if err != nil {
+		return nil, err
+	}
+	defer reviews.Close()
+
+	res := map[anki.ID]int{}
+	for reviews.Next() {
+		review, err := reviews.Review()
+		if err != nil {
+			return nil, err
+		}
+		// Reviews are ordered newest first. The review id is in milliseconds, but the anki library scans it as seconds.
+		if review.Timestamp != nil {
+			reviewed := time.Unix(0, time.Time(*review.Timestamp).Unix()*int64(time.Millisecond))
+			if reviewed.Before(since) {
+				break
+			}
+		}
+		if review.Ease == anki.ReviewEaseWrong {
+			res[review.CardID]++
+		}
+	}
+	return res, reviews.Err()
+}
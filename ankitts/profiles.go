@@ -0,0 +1,130 @@
+package ankitts
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// AnkiBaseDirCandidates returns the possible locations of the Anki base folder, in order of preference.
+func AnkiBaseDirCandidates() ([]string, error) {
+	if base := os.Getenv("ANKI_BASE"); base != "" {
+		return []string{base}, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		res = append(res, path.Join(xdg, "Anki2"))
+	}
+	return append(res,
+		path.Join(usr.HomeDir, ".local", "share", "Anki2"),
+		path.Join(usr.HomeDir, "Library", "Application Support", "Anki2"),
+		path.Join(usr.HomeDir, "Anki"),
+	), nil
+}
+
+func FindAnkiBaseDir() (string, error) {
+	candidates, err := AnkiBaseDirCandidates()
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range candidates {
+		if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("Anki base folder not found (tried %s), set ANKI_BASE or use -c", strings.Join(candidates, ", "))
+}
+
+// ListProfiles returns profile names from prefs21.db, or (for older Anki versions without it) the subdirectories
+// containing a collection.
+func ListProfiles(baseDir string) ([]string, error) {
+	prefsDb := path.Join(baseDir, "prefs21.db")
+	if _, err := os.Stat(prefsDb); err == nil {
+		return listProfilesFromPrefs(prefsDb)
+	}
+
+	files, err := ioutil.ReadDir(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, f := range files {
+		if _, err := os.Stat(path.Join(baseDir, f.Name(), "collection.anki2")); f.IsDir() && err == nil {
+			res = append(res, f.Name())
+		}
+	}
+	return res, nil
+}
+
+func listProfilesFromPrefs(prefsDb string) ([]string, error) {
+	dsn := (&url.URL{Scheme: "file", Path: prefsDb, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("select name from profiles where name != '_global'")
+	if err != nil {
+		return nil, fmt.Errorf("reading profiles from %s: %s", prefsDb, err.Error())
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res, rows.Err()
+}
+
+// FindProfileDir finds the profile directory by name. An exact (case insensitive) match wins, otherwise the name must
+// be a prefix of exactly one profile. If name is empty, the collection must have only one profile.
+func FindProfileDir(baseDir, name string) (string, error) {
+	profiles, err := ListProfiles(baseDir)
+	if err != nil {
+		return "", err
+	}
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("no profiles found in %s", baseDir)
+	}
+
+	var matches []string
+	for _, profile := range profiles {
+		if strings.EqualFold(profile, name) {
+			return path.Join(baseDir, profile), nil
+		}
+		if strings.HasPrefix(strings.ToLower(profile), strings.ToLower(name)) {
+			matches = append(matches, profile)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return path.Join(baseDir, matches[0]), nil
+	case len(matches) == 0:
+		return "", fmt.Errorf("profile %s not found in %s, available profiles: %s", name, baseDir, strings.Join(profiles, ", "))
+	case name == "":
+		return "", fmt.Errorf("several profiles found in %s, select one with -profile: %s", baseDir, strings.Join(matches, ", "))
+	default:
+		return "", fmt.Errorf("several profiles match %s: %s", name, strings.Join(matches, ", "))
+	}
+}
@@ -0,0 +1,386 @@
+package ankitts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tkrajina/anki"
+)
+
+// QueryContext is one card (with its note) to be matched against a query.
+type QueryContext struct {
+	Collection *anki.Collection
+	Note       *anki.Note
+	Card       *anki.Card
+	Now        time.Time
+}
+
+func (qc QueryContext) model() *anki.Model {
+	return qc.Collection.Models[qc.Note.ModelID]
+}
+
+// Query is a parsed search, modeled on the Anki search syntax:
+//
+//	deck:"German::Verbs" tag:audio-missing -is:suspended note:Basic Back:* nid:123 added:7 (dog or cat)
+type Query interface {
+	Match(qc QueryContext) bool
+}
+
+type andQuery []Query
+
+func (aq andQuery) Match(qc QueryContext) bool {
+	for _, q := range aq {
+		if !q.Match(qc) {
+			return false
+		}
+	}
+	return true
+}
+
+type orQuery []Query
+
+func (oq orQuery) Match(qc QueryContext) bool {
+	for _, q := range oq {
+		if q.Match(qc) {
+			return true
+		}
+	}
+	return false
+}
+
+type notQuery struct {
+	Query
+}
+
+func (nq notQuery) Match(qc QueryContext) bool {
+	return !nq.Query.Match(qc)
+}
+
+type matcherFunc func(qc QueryContext) bool
+
+func (mf matcherFunc) Match(qc QueryContext) bool {
+	return mf(qc)
+}
+
+type queryToken struct {
+	text string
+	// quoted tokens are never operators or parentheses
+	quoted bool
+}
+
+func tokenizeQuery(str string) ([]queryToken, error) {
+	var res []queryToken
+	var current strings.Builder
+	var inQuotes, quoted, started bool
+	flush := func() {
+		if started {
+			res = append(res, queryToken{text: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		quoted, started = false, false
+	}
+	for _, r := range str {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			quoted, started = true, true
+		case inQuotes:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			if !(r == '(' && current.String() == "-") {
+				flush()
+			}
+			current.WriteRune(r)
+			started = true
+			flush()
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %s", str)
+	}
+	flush()
+	return res, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func ParseQuery(str string) (Query, error) {
+	tokens, err := tokenizeQuery(str)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return andQuery{}, nil
+	}
+	p := &queryParser{tokens: tokens}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s in %s", p.tokens[p.pos].text, str)
+	}
+	return q, nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *queryParser) isOperator(t queryToken, op string) bool {
+	return !t.quoted && strings.EqualFold(t.text, op)
+}
+
+func (p *queryParser) parseOr() (Query, error) {
+	var res orQuery
+	for {
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+		t, ok := p.peek()
+		if !ok || !p.isOperator(t, "or") {
+			break
+		}
+		p.pos++
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (p *queryParser) parseAnd() (Query, error) {
+	var res andQuery
+	for {
+		t, ok := p.peek()
+		if !ok || p.isOperator(t, "or") || (!t.quoted && t.text == ")") {
+			break
+		}
+		if p.isOperator(t, "and") {
+			p.pos++
+			continue
+		}
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (p *queryParser) parseUnary() (Query, error) {
+	t, _ := p.peek()
+	p.pos++
+	if !t.quoted && (t.text == "(" || t.text == "-(") {
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.quoted || closing.text != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		if t.text == "-(" {
+			return notQuery{q}, nil
+		}
+		return q, nil
+	}
+	if strings.HasPrefix(t.text, "-") && len(t.text) > 1 {
+		q, err := parseQueryTerm(t.text[1:])
+		return notQuery{q}, err
+	}
+	return parseQueryTerm(t.text)
+}
+
+// globRegexp converts an Anki glob (* is any text, _ is one character) to a case insensitive regexp.
+func globRegexp(glob string, full bool) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("(?is)")
+	if full {
+		expr.WriteString("^")
+	}
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if full {
+		expr.WriteString("$")
+	}
+	return regexp.Compile(expr.String())
+}
+
+func parseIDs(str string) (map[anki.ID]bool, error) {
+	res := map[anki.ID]bool{}
+	for _, part := range strings.Split(str, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %s", part)
+		}
+		res[anki.ID(id)] = true
+	}
+	return res, nil
+}
+
+func parseQueryTerm(term string) (Query, error) {
+	parts := strings.SplitN(term, ":", 2)
+	if len(parts) < 2 {
+		re, err := globRegexp(term, false)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool {
+			for _, value := range qc.Note.FieldValues {
+				if re.MatchString(value) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	}
+
+	key, value := strings.ToLower(parts[0]), parts[1]
+	switch key {
+	case "deck":
+		dm, err := NewDeckMatcher(value, true)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool {
+			return dm.MatchCard(qc.Collection, *qc.Card)
+		}), nil
+	case "note":
+		re, err := globRegexp(value, true)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool {
+			model := qc.model()
+			return model != nil && re.MatchString(model.Name)
+		}), nil
+	case "tag":
+		if strings.EqualFold(value, "none") {
+			return matcherFunc(func(qc QueryContext) bool {
+				return strings.TrimSpace(qc.Note.Tags) == ""
+			}), nil
+		}
+		re, err := globRegexp(value, true)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool {
+			for _, tag := range strings.Fields(qc.Note.Tags) {
+				// tag:a also matches child tags (a::b)
+				if re.MatchString(tag) || re.MatchString(strings.SplitN(tag, "::", 2)[0]) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "is":
+		return parseIsTerm(value)
+	case "nid":
+		ids, err := parseIDs(value)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool { return ids[qc.Note.ID] }), nil
+	case "cid":
+		ids, err := parseIDs(value)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool { return ids[qc.Card.ID] }), nil
+	case "mid":
+		ids, err := parseIDs(value)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(qc QueryContext) bool { return ids[qc.Note.ModelID] }), nil
+	case "added":
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("invalid added:%s", value)
+		}
+		return matcherFunc(func(qc QueryContext) bool {
+			// the card id is the creation time in milliseconds
+			created := time.Unix(0, int64(qc.Card.ID)*int64(time.Millisecond))
+			return qc.Now.Sub(created) < time.Duration(days)*24*time.Hour
+		}), nil
+	}
+
+	// field:value
+	fieldName := parts[0]
+	re, err := globRegexp(value, true)
+	if err != nil {
+		return nil, err
+	}
+	return matcherFunc(func(qc QueryContext) bool {
+		model := qc.model()
+		if model == nil {
+			return false
+		}
+		for n, field := range model.Fields {
+			if strings.EqualFold(field.Name, fieldName) && n < len(qc.Note.FieldValues) {
+				return re.MatchString(qc.Note.FieldValues[n])
+			}
+		}
+		return false
+	}), nil
+}
+
+func parseIsTerm(value string) (Query, error) {
+	var fn func(card *anki.Card, now time.Time) bool
+	switch strings.ToLower(value) {
+	case "new":
+		fn = func(c *anki.Card, now time.Time) bool { return c.Type == anki.CardTypeNew }
+	case "learn":
+		fn = func(c *anki.Card, now time.Time) bool {
+			return c.Queue == anki.CardQueueLearning || c.Queue == anki.CardQueueRelearning
+		}
+	case "review":
+		fn = func(c *anki.Card, now time.Time) bool { return c.Type == anki.CardTypeReview }
+	case "suspended":
+		fn = func(c *anki.Card, now time.Time) bool { return c.Queue == anki.CardQueueSuspended }
+	case "buried":
+		fn = func(c *anki.Card, now time.Time) bool {
+			return c.Queue == anki.CardQueueBuried || c.Queue == anki.CardQueueSchedBuried
+		}
+	case "due":
+		fn = func(c *anki.Card, now time.Time) bool {
+			return (c.Queue == anki.CardQueueReview || c.Queue == anki.CardQueueLearning) && c.Due != nil && !time.Time(*c.Due).After(now)
+		}
+	default:
+		return nil, fmt.Errorf("unknown is:%s", value)
+	}
+	return matcherFunc(func(qc QueryContext) bool { return fn(qc.Card, qc.Now) }), nil
+}
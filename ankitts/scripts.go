@@ -0,0 +1,80 @@
+package ankitts
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ScriptMismatch is prepared text with letters in scripts the voices of the locale can't read.
+type ScriptMismatch struct {
+	Locale  string   `json:"locale"`
+	Scripts []string `json:"scripts"`
+	Text    string   `json:"text"`
+}
+
+// ScriptMismatchModes are the values of Params.ScriptMismatch: warn (default), skip the text, speak it in the
+// fallback locale, or ignore the scripts.
+var ScriptMismatchModes = []string{"warn", "skip", "fallback", "ignore"}
+
+var latinLanguages = []string{"af", "ca", "cs", "cy", "da", "de", "en", "es", "et", "eu", "fi", "fil", "fr", "ga", "gl",
+	"hr", "hu", "id", "is", "it", "lt", "lv", "ms", "mt", "nb", "nl", "nn", "no", "pl", "pt", "ro", "sk", "sl", "sq", "sv",
+	"sw", "tr", "vi"}
+
+// Expected scripts (names in unicode.Scripts) by language besides Latin, text in other languages isn't checked.
+var languageScripts = map[string][]string{
+	"ru": {"Cyrillic"}, "uk": {"Cyrillic"}, "be": {"Cyrillic"}, "bg": {"Cyrillic"}, "mk": {"Cyrillic"},
+	"kk": {"Cyrillic"}, "sr": {"Cyrillic"}, "el": {"Greek"}, "he": {"Hebrew"}, "ar": {"Arabic"}, "fa": {"Arabic"},
+	"ur": {"Arabic"}, "hi": {"Devanagari"}, "mr": {"Devanagari"}, "bn": {"Bengali"}, "ta": {"Tamil"}, "te": {"Telugu"},
+	"th": {"Thai"}, "ka": {"Georgian"}, "hy": {"Armenian"}, "ja": {"Han", "Hiragana", "Katakana"}, "zh": {"Han"},
+	"ko": {"Hangul", "Han"},
+}
+
+func init() {
+	for _, language := range latinLanguages {
+		languageScripts[language] = []string{}
+	}
+}
+
+// UnexpectedScripts returns the (sorted) names of the scripts of letters in the text which aren't expected in the
+// locale, SSML tags are ignored. Latin letters are expected in other scripts too (abbreviations and names like CD or
+// iPhone), but not in text without any letter of the locale's scripts. The result is empty for languages without
+// expected scripts.
+func UnexpectedScripts(text, locale string) []string {
+	expected, found := languageScripts[strings.ToLower(strings.SplitN(locale, "-", 2)[0])]
+	if !found {
+		return nil
+	}
+	var tables []*unicode.RangeTable
+	for _, name := range expected {
+		tables = append(tables, unicode.Scripts[name])
+	}
+	unexpected := map[string]bool{}
+	var latin, native bool
+	for _, r := range ssmlTagRegexp.ReplaceAllString(text, " ") {
+		switch {
+		case !unicode.IsLetter(r):
+			continue
+		case unicode.In(r, tables...):
+			native = true
+			continue
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+			continue
+		}
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+				unexpected[name] = true
+			}
+		}
+	}
+	if latin && !native && len(expected) > 0 {
+		unexpected["Latin"] = true
+	}
+	var res []string
+	for name := range unexpected {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
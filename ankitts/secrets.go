@@ -0,0 +1,111 @@
+package ankitts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"strings"
+)
+
+const (
+	speechApiKeyName = "speech_bing_api_key"
+	keyringService   = "anki-tts"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+type Keyring interface {
+	Get(service, key string) (string, error)
+}
+
+// SecretServiceKeyring reads secrets from the Secret Service (GNOME Keyring, KWallet) using secret-tool. Store the key
+// with:
+//
+//	secret-tool store --label anki-tts service anki-tts key speech_bing_api_key
+type SecretServiceKeyring struct{}
+
+var _ Keyring = SecretServiceKeyring{}
+
+func (SecretServiceKeyring) Get(service, key string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", ErrSecretNotFound
+	}
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "key", key).Output()
+	if err != nil {
+		// secret-tool exits with 1 (and no output) if the secret doesn't exist
+		return "", ErrSecretNotFound
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func DefaultSecretsFile() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(usr.HomeDir, ".anki-tts-secrets"), nil
+}
+
+// readSecretsFile reads a JSON file with secrets, the file must not be readable by other users.
+func readSecretsFile(fn string) (map[string]string, error) {
+	stat, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s must be readable only by the owner (chmod 600 %s), mode is %s", fn, fn, stat.Mode().Perm())
+	}
+	byts, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{}
+	if err := json.Unmarshal(byts, &res); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %s", fn, err.Error())
+	}
+	return res, nil
+}
+
+// ResolveSpeechApiKey finds the API key, from (in order): the ANKI_TTS_SPEECH_BING_API_KEY environment variable, the
+// secrets file, the keyring and the config file.
+func ResolveSpeechApiKey(config Config, keyring Keyring) (string, error) {
+	if key := os.Getenv("ANKI_TTS_" + strings.ToUpper(speechApiKeyName)); key != "" {
+		return key, nil
+	}
+
+	secretsFile := config.SecretsFile
+	if secretsFile == "" {
+		fn, err := DefaultSecretsFile()
+		if err != nil {
+			return "", err
+		}
+		secretsFile = fn
+	}
+	secrets, err := readSecretsFile(secretsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if key := secrets[speechApiKeyName]; key != "" {
+		return key, nil
+	}
+
+	if keyring != nil {
+		key, err := keyring.Get(keyringService, speechApiKeyName)
+		if err == nil && key != "" {
+			return key, nil
+		}
+		if err != nil && err != ErrSecretNotFound {
+			return "", err
+		}
+	}
+
+	if config.SpeechApiKey != "" {
+		return config.SpeechApiKey, nil
+	}
+	return "", fmt.Errorf("no API key, set it in ANKI_TTS_SPEECH_BING_API_KEY, %s, the keyring or the config file", secretsFile)
+}
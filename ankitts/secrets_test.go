@@ -0,0 +1,123 @@
+package ankitts
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// fakeKeyring is a Keyring backed by a map with "service/key" keys.
+type fakeKeyring map[string]string
+
+var _ Keyring = fakeKeyring{}
+
+func (fk fakeKeyring) Get(service, key string) (string, error) {
+	if secret, found := fk[service+"/"+key]; found {
+		return secret, nil
+	}
+	return "", ErrSecretNotFound
+}
+
+// failingKeyring is a keyring which can't be read.
+type failingKeyring struct{}
+
+func (failingKeyring) Get(service, key string) (string, error) {
+	return "", errors.New("keyring locked")
+}
+
+func writeSecretsFile(t *testing.T, content string, mode os.FileMode) string {
+	fn := path.Join(t.TempDir(), "secrets.json")
+	if err := ioutil.WriteFile(fn, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(fn, mode); err != nil {
+		t.Fatal(err)
+	}
+	return fn
+}
+
+func TestResolveSpeechApiKey(t *testing.T) {
+	keyring := fakeKeyring{"anki-tts/speech_bing_api_key": "from-keyring"}
+	for _, test := range []struct {
+		name    string
+		env     string
+		secrets string
+		keyring Keyring
+		config  string
+		key     string
+		err     string
+	}{
+		{name: "environment first", env: "from-env", secrets: `{"speech_bing_api_key": "from-file"}`, keyring: keyring, config: "from-config", key: "from-env"},
+		{name: "secrets file before keyring", secrets: `{"speech_bing_api_key": "from-file"}`, keyring: keyring, config: "from-config", key: "from-file"},
+		{name: "keyring before config", secrets: `{}`, keyring: keyring, config: "from-config", key: "from-keyring"},
+		{name: "no secrets file", keyring: keyring, config: "from-config", key: "from-keyring"},
+		{name: "config last", keyring: fakeKeyring{}, config: "from-config", key: "from-config"},
+		{name: "no keyring", config: "from-config", key: "from-config"},
+		{name: "keyring error", keyring: failingKeyring{}, config: "from-config", err: "keyring locked"},
+		{name: "no key", keyring: fakeKeyring{}, err: "no API key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("ANKI_TTS_SPEECH_BING_API_KEY", test.env)
+			config := Config{SpeechApiKey: test.config, SecretsFile: path.Join(t.TempDir(), "missing.json")}
+			if test.secrets != "" {
+				config.SecretsFile = writeSecretsFile(t, test.secrets, 0600)
+			}
+			key, err := ResolveSpeechApiKey(config, test.keyring)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != test.key {
+				t.Errorf("expected %q, got %q", test.key, key)
+			}
+		})
+	}
+}
+
+func TestReadSecretsFile(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		key     string
+		err     string
+	}{
+		{name: "owner only", content: `{"speech_bing_api_key": "k"}`, mode: 0600, key: "k"},
+		{name: "owner read only", content: `{"speech_bing_api_key": "k"}`, mode: 0400, key: "k"},
+		{name: "readable by group", content: `{"speech_bing_api_key": "k"}`, mode: 0640, err: "chmod 600"},
+		{name: "readable by others", content: `{"speech_bing_api_key": "k"}`, mode: 0604, err: "chmod 600"},
+		{name: "invalid JSON", content: `speech_bing_api_key=k`, mode: 0600, err: "unmarshalling"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			secrets, err := readSecretsFile(writeSecretsFile(t, test.content, test.mode))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secrets[speechApiKeyName] != test.key {
+				t.Errorf("expected %q, got %q", test.key, secrets[speechApiKeyName])
+			}
+		})
+	}
+}
+
+func TestResolveSpeechApiKeyRejectsOpenSecretsFile(t *testing.T) {
+	t.Setenv("ANKI_TTS_SPEECH_BING_API_KEY", "")
+	config := Config{SpeechApiKey: "from-config", SecretsFile: writeSecretsFile(t, `{"speech_bing_api_key": "k"}`, 0644)}
+	if _, err := ResolveSpeechApiKey(config, fakeKeyring{}); err == nil {
+		t.Fatal("expected an error for a secrets file readable by others")
+	}
+}
@@ -0,0 +1,95 @@
+package ankitts
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Segment is a part of a text in one language. Language is the lang attribute or marker (en, en-GB), empty for text
+// in the job's locale. Locale and Voice are set when the segment is prepared for synthesis.
+type Segment struct {
+	Language string `json:"language,omitempty"`
+	Locale   string `json:"locale"`
+	Voice    string `json:"voice,omitempty"`
+	Text     string `json:"text"`
+}
+
+var langAttrRegexp = regexp.MustCompile(`(?i)\slang\s*=`)
+
+// Elements which separate words, replaced by a space
+var blockElements = map[string]bool{"br": true, "div": true, "p": true, "li": true, "tr": true, "td": true}
+
+// SplitLanguages splits field HTML into language segments at elements with a lang attribute (<span lang="en">) and at
+// matches of marker, a regexp with the language in the first and the text in the second group (for example
+// `\{\{(\w+):(.*?)\}\}` for {{en:the house}}, marker can be nil). The segments' text is HTML, adjacent segments in the
+// same language are joined. Text without languages is returned unchanged as one segment.
+func SplitLanguages(text string, marker *regexp.Regexp) []Segment {
+	if marker != nil {
+		text = marker.ReplaceAllStringFunc(text, func(match string) string {
+			groups := marker.FindStringSubmatch(match)
+			if len(groups) < 3 {
+				return match
+			}
+			return `<span lang="` + html.EscapeString(groups[1]) + `">` + groups[2] + `</span>`
+		})
+	}
+	if !langAttrRegexp.MatchString(text) {
+		return []Segment{{Text: text}}
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(text), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return []Segment{{Text: text}}
+	}
+	var res []Segment
+	add := func(language, str string) {
+		if len(res) > 0 && strings.EqualFold(res[len(res)-1].Language, language) {
+			res[len(res)-1].Text += str
+			return
+		}
+		res = append(res, Segment{Language: language, Text: str})
+	}
+	var walk func(node *html.Node, language string)
+	walk = func(node *html.Node, language string) {
+		switch node.Type {
+		case html.TextNode:
+			add(language, html.EscapeString(node.Data))
+			return
+		case html.ElementNode:
+			for _, attr := range node.Attr {
+				if strings.EqualFold(attr.Key, "lang") {
+					language = strings.TrimSpace(attr.Val)
+				}
+			}
+			if blockElements[node.Data] {
+				add(language, " ")
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, language)
+		}
+	}
+	for _, node := range nodes {
+		walk(node, "")
+	}
+	return res
+}
+
+// SegmentsText is the text of the segments with the ones not in locale wrapped in <lang xml:lang="..."> (and <voice>
+// if the voice differs), used for the media filenames and hashes.
+func SegmentsText(segments []Segment, locale, voice string) string {
+	var parts []string
+	for _, s := range segments {
+		text := s.Text
+		if s.Voice != voice {
+			text = `<voice name="` + html.EscapeString(s.Voice) + `">` + text + `</voice>`
+		}
+		if !strings.EqualFold(s.Locale, locale) {
+			text = `<lang xml:lang="` + s.Locale + `">` + text + `</lang>`
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/tkrajina/bingtts"
 	"io/ioutil"
+	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -16,22 +18,59 @@ const (
 	Female Gender = "female"
 )
 
-func Retrieve(params Params, config Config, gender Gender, text string, targetDir, destFilename string) error {
+// bingFormats are the supported output formats of the Bing engine.
+var bingFormats = map[string]bingtts.OutputType{
+	"mp3": bingtts.Audio16khz32kbitrateMonoMp3,
+	"wav": bingtts.RIFF16Bit16kHzMonoPCM,
+}
+
+// Retrieve synthesizes the text into destFilename. Text longer than the engine's limit (see EngineTextLimits) is split
+// into chunks which are synthesized separately and joined into one file.
+func Retrieve(params Params, config Config, text string, targetDir, destFilename string) error {
+	return RetrieveSegments(params, config, []Segment{{Locale: params.LanguageLocale, Voice: params.Voice, Text: text}}, destFilename)
+}
+
+// RetrieveSegments synthesizes every segment with its locale and voice and joins the audio into destFilename. The
+// engine's SSML has one voice per request, so texts in several languages are synthesized one segment at a time. The
+// segments' text is escaped with EscapeSSML.
+func RetrieveSegments(params Params, config Config, segments []Segment, destFilename string) error {
+	if params.Engine != "" && params.Engine != "bing" {
+		return fmt.Errorf("unsupported engine %s", params.Engine)
+	}
+	format := params.AudioFormat()
+	outputType, found := bingFormats[format]
+	if !found {
+		return fmt.Errorf("unsupported format %s for bing", format)
+	}
+
 	token, err := bingtts.IssueToken(config.SpeechApiKey)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("token=", token)
+	var parts [][]byte
+	for _, segment := range segments {
+		gender := VoiceGender(segment.Locale, segment.Voice)
+		for _, chunk := range SplitText(EscapeSSML(segment.Text), EngineTextLimits["bing"]) {
+			// Synthesize
+			res, err := bingtts.Synthesize(
+				token,
+				chunk,
+				segment.Locale,
+				bingtts.Gender(gender),
+				segment.Voice,
+				outputType)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, res)
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("no text to synthesize")
+	}
 
-	// Synthesize
-	res, err := bingtts.Synthesize(
-		token,
-		text,
-		params.LanguageLocale,
-		bingtts.Gender(gender),
-		"",
-		bingtts.Audio16khz32kbitrateMonoMp3)
+	res, err := ConcatAudio(format, parts)
 	if err != nil {
 		return err
 	}
@@ -43,6 +82,67 @@ func Retrieve(params Params, config Config, gender Gender, text string, targetDi
 	return nil
 }
 
+// SSML elements (with the engines' prefixed extensions like mstts:express-as) and entities, kept by EscapeSSML
+var ssmlMarkupRegexp = regexp.MustCompile(`(?i)</?(speak|voice|lang|phoneme|sub|break|say-as|prosody|emphasis|s|p|audio|mark|[a-z]+:[\w-]+)(\s[^<>]*)?/?>|&(#\d+|#x[0-9a-f]+|[a-z]+);`)
+
+var ssmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeSSML escapes &, < and > in the text, the text is sent as SSML. SSML elements (from the lexicon, cloze clips
+// or override fields) and entities are kept, so text which is already escaped isn't changed.
+func EscapeSSML(text string) string {
+	var res strings.Builder
+	last := 0
+	for _, loc := range ssmlMarkupRegexp.FindAllStringIndex(text, -1) {
+		res.WriteString(ssmlTextEscaper.Replace(text[last:loc[0]]))
+		res.WriteString(text[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	res.WriteString(ssmlTextEscaper.Replace(text[last:]))
+	return res.String()
+}
+
+// VoiceGender finds the gender of a voice (female if no voice is set).
+func VoiceGender(locale, voiceName string) Gender {
+	if voiceName == "" {
+		return Female
+	}
+	for _, voices := range bingtts.GetVoices() {
+		for _, v := range voices {
+			if strings.EqualFold(v.Locale, locale) && strings.Contains(strings.ToLower(v.VoiceName), strings.ToLower(voiceName)) {
+				return Gender(v.Gender)
+			}
+		}
+	}
+	return Female
+}
+
+// DefaultLocale is the locale used for a language without a configured locale: the language's "main" locale (de-DE,
+// fr-FR), en-US, or the first locale with voices for the language.
+func DefaultLocale(language string) string {
+	language = strings.ToLower(language)
+	candidates := []string{language + "-" + strings.ToUpper(language), "en-US"}
+	var locales []string
+	for _, voices := range bingtts.GetVoices() {
+		for _, v := range voices {
+			if strings.HasPrefix(strings.ToLower(v.Locale), language+"-") {
+				locales = append(locales, v.Locale)
+			}
+		}
+	}
+	sort.Strings(locales)
+	for _, candidate := range candidates {
+		for _, locale := range locales {
+			if strings.EqualFold(locale, candidate) {
+				return locale
+			}
+		}
+	}
+	if len(locales) > 0 {
+		return locales[0]
+	}
+	return language
+}
+
 func PrepareDestfilename(text string) string {
 	var res bytes.Buffer
 	for _, r := range text {
@@ -0,0 +1,180 @@
+package ankitts
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var cyrillicTranslit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f",
+	'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Ukrainian, Belarusian, Serbian and Macedonian
+	'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+	'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+var greekTranslit = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l",
+	'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f",
+	'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// Latin letters without a decomposition into a base letter and diacritics
+var latinTranslit = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i", 'ŋ': "ng", 'ħ': "h",
+}
+
+// Hepburn romanization of hiragana, katakana is mapped to hiragana first
+var kanaTranslit = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o", 'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so", 'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te",
+	'と': "to", 'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no", 'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he",
+	'ほ': "ho", 'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo", 'や': "ya", 'ゆ': "yu", 'よ': "yo", 'ら': "ra",
+	'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro", 'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'が': "ga",
+	'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go", 'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo", 'だ': "da",
+	'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do", 'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo", 'ぱ': "pa",
+	'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po", 'ゔ': "vu",
+}
+
+// Small kana combining with the previous one (kya, sha, fa, ...)
+var smallKana = map[rune]string{
+	'ゃ': "a", 'ゅ': "u", 'ょ': "o", 'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "a",
+}
+
+// Pinyin (without tones) of common Han characters, others are left out of transliterated names.
+var pinyinTable = `的de 一yi 是shi 不bu 了le 人ren 我wo 在zai 有you 他ta 这zhe 中zhong 大da 来lai 上shang 国guo 个ge 到dao
+说shuo 们men 为wei 子zi 和he 你ni 地di 出chu 道dao 也ye 时shi 年nian 得de 就jiu 那na 要yao 下xia 以yi 生sheng 会hui
+自zi 着zhe 去qu 之zhi 过guo 家jia 学xue 对dui 可ke 她ta 里li 后hou 小xiao 么me 心xin 多duo 天tian 而er 能neng 好hao
+都dou 然ran 没mei 日ri 于yu 起qi 还hai 发fa 成cheng 事shi 只zhi 作zuo 当dang 想xiang 看kan 文wen 无wu 开kai 手shou
+十shi 用yong 主zhu 行xing 方fang 又you 如ru 前qian 所suo 本ben 见jian 经jing 头tou 面mian 公gong 同tong 三san 已yi
+老lao 从cong 动dong 两liang 长chang 知zhi 民min 样yang 现xian 分fen 将jiang 外wai 但dan 身shen 些xie 与yu 高gao 意yi
+进jin 把ba 法fa 此ci 实shi 回hui 二er 理li 美mei 点dian 月yue 明ming 其qi 种zhong 声sheng 全quan 工gong 己ji 话hua
+儿er 者zhe 向xiang 情qing 部bu 正zheng 名ming 定ding 女nu 问wen 力li 机ji 给gei 等deng 几ji 很hen 业ye 最zui 间jian
+新xin 什shen 打da 便bian 位wei 因yin 重zhong 被bei 走zou 电dian 四si 第di 门men 相xiang 次ci 东dong 政zheng 海hai
+口kou 使shi 教jiao 西xi 再zai 平ping 真zhen 听ting 世shi 气qi 信xin 北bei 少shao 关guan 并bing 内nei 加jia 化hua
+由you 却que 代dai 军jun 产chan 入ru 先xian 山shan 五wu 太tai 水shui 万wan 市shi 眼yan 体ti 别bie 处chu 总zong 才cai
+场chang 师shi 书shu 比bi 住zhu 员yuan 九jiu 笑xiao 性xing 通tong 目mu 华hua 报bao 立li 马ma 命ming 张zhang 活huo
+难nan 神shen 数shu 件jian 安an 表biao 原yuan 车che 白bai 应ying 路lu 期qi 叫jiao 死si 常chang 提ti 感gan 金jin 何he
+更geng 反fan 合he 放fang 做zuo 系xi 计ji 或huo 司si 利li 受shou 光guang 王wang 果guo 亲qin 界jie 及ji 今jin 京jing
+务wu 制zhi 解jie 各ge 任ren 至zhi 清qing 物wu 台tai 象xiang 记ji 边bian 共gong 风feng 战zhan 干gan 接jie 它ta 许xu
+八ba 特te 觉jue 望wang 直zhi 服fu 毛mao 林lin 题ti 建jian 南nan 度du 统tong 色se 字zi 请qing 交jiao 爱ai 让rang
+认ren 算suan 论lun 百bai 吃chi 义yi 科ke 怎zen 元yuan 社she 术shu 结jie 六liu 功gong 指zhi 思si 非fei 流liu 每mei
+青qing 管guan 夫fu 连lian 远yuan 资zi 队dui 跟gen 带dai 花hua 快kuai 条tiao 院yuan 变bian 联lian 言yan 权quan
+往wang 展zhan 该gai 领ling 传chuan 近jin 留liu 红hong 治zhi 决jue 周zhou 保bao 达da 办ban 运yun 武wu 半ban 候hou
+七qi 必bi 城cheng 父fu 强qiang 步bu 完wan 革ge 深shen 区qu 即ji 求qiu 品pin 士shi 转zhuan 量liang 空kong 甚shen
+众zhong 技ji 轻qing 程cheng 告gao 江jiang 语yu 英ying 基ji 派pai 满man 式shi 李li 息xi 写xie 呢ne 识shi 极ji
+令ling 黄huang 德de 收shou 脸lian 钱qian 党dang 倒dao 未wei 持chi 取qu 设she 始shi 版ban 双shuang 历li 越yue
+史shi 商shang 千qian 片pian 容rong 研yan 像xiang 找zhao 友you 孩hai 站zhan 广guang 改gai 议yi 形xing 委wei 早zao
+房fang 音yin 火huo 际ji 则ze 首shou 单dan 猫mao 狗gou 鱼yu 鸟niao 吗ma 喝he 茶cha 饭fan 米mi 肉rou 汉han 朋peng
+妈ma 爸ba 哥ge 姐jie 弟di 妹mei 谢xie 您nin 昨zuo 星xing 岁sui 块kuai 钟zhong 买mai 卖mai 贵gui 雨yu 雪xue 冷leng
+热re 病bing 医yi 喜xi 欢huan 睡shui 读du 坐zuo 飞fei 票piao 店dian 菜cai 桌zhuo 椅yi 衣yi 穿chuan 左zuo 右you 晚wan
+午wu 号hao 零ling`
+
+var pinyin = func() map[rune]string {
+	res := map[rune]string{}
+	for _, entry := range strings.Fields(pinyinTable) {
+		r := []rune(entry)
+		res[r[0]] = string(r[1:])
+	}
+	return res
+}()
+
+// replacePrevious replaces the last n bytes.
+func replacePrevious(res *strings.Builder, n int, str string) {
+	current := res.String()
+	res.Reset()
+	res.WriteString(current[:len(current)-n])
+	res.WriteString(str)
+}
+
+// Transliterate converts text to ASCII: Cyrillic, Greek, Latin with diacritics, kana (Hepburn romaji) and common Han
+// characters (Pinyin syllables separated by spaces, not for Japanese). Other characters, including kanji and Han
+// characters missing in pinyinTable, are replaced by spaces.
+func Transliterate(text, locale string) string {
+	japanese := strings.HasPrefix(strings.ToLower(locale), "ja")
+	var res strings.Builder
+	var previous string
+	geminate := false
+	write := func(str string, upper bool) {
+		if geminate && str != "" {
+			if strings.HasPrefix(str, "ch") {
+				res.WriteString("t")
+			} else if !strings.ContainsRune("aeioun", rune(str[0])) {
+				res.WriteByte(str[0])
+			}
+			geminate = false
+		}
+		if upper && str != "" {
+			str = strings.ToUpper(str[:1]) + str[1:]
+		}
+		res.WriteString(str)
+		previous = str
+	}
+
+	for _, r := range norm.NFC.String(text) {
+		upper := unicode.IsUpper(r)
+		lower := unicode.ToLower(r)
+		// Katakana to hiragana
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 0x60
+		}
+		switch {
+		case r < 0x80:
+			write(string(r), false)
+		case r == 'っ':
+			geminate = true
+		case r == 'ー':
+			// long vowel
+			if previous != "" {
+				write(previous[len(previous)-1:], false)
+			}
+		case smallKana[r] != "":
+			vowel := smallKana[r]
+			yoon := strings.ContainsRune("ゃゅょ", r)
+			switch {
+			case yoon && (previous == "shi" || previous == "chi" || previous == "ji"):
+				replacePrevious(&res, 1, vowel)
+			case yoon && strings.HasSuffix(previous, "i"):
+				replacePrevious(&res, 1, "y"+vowel)
+			case yoon:
+				write("y"+vowel, false)
+			case previous == "u":
+				replacePrevious(&res, 1, "w"+vowel)
+			case previous != "" && strings.ContainsRune("aeiou", rune(previous[len(previous)-1])):
+				// fa, ti, che, ...
+				replacePrevious(&res, 1, vowel)
+			default:
+				write(vowel, false)
+			}
+			previous = vowel
+		case kanaTranslit[r] != "":
+			write(kanaTranslit[r], false)
+		case pinyin[r] != "" && !japanese:
+			// One word per syllable
+			write(" "+pinyin[r]+" ", false)
+		case cyrillicTranslit[lower] != "" || (lower >= 'а' && lower <= 'я'):
+			write(cyrillicTranslit[lower], upper)
+		default:
+			var ascii strings.Builder
+			for _, d := range norm.NFD.String(string(lower)) {
+				switch {
+				case d < 0x80:
+					ascii.WriteRune(d)
+				case greekTranslit[d] != "":
+					ascii.WriteString(greekTranslit[d])
+				case latinTranslit[d] != "":
+					ascii.WriteString(latinTranslit[d])
+				}
+			}
+			if ascii.Len() == 0 {
+				write(" ", false)
+			} else {
+				write(ascii.String(), upper)
+			}
+		}
+	}
+	return res.String()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+	"github.com/tkrajina/anki"
+)
+
+type cleanResult struct {
+	RunID  string   `json:"run_id,omitempty"`
+	DryRun bool     `json:"dry_run"`
+	Purged bool     `json:"purged"`
+	Trash  string   `json:"trash,omitempty"`
+	Files  []string `json:"files"`
+	Bytes  int64    `json:"bytes"`
+}
+
+// Names with a locale prefix and the hash of the default naming scheme
+var hashedMediaRegexp = regexp.MustCompile(`^[a-z]{2,3}-[A-Za-z]{2,4}-.*[0-9a-f]{12}(-\d+)?\.(mp3|wav|ogg)$`)
+
+func cleanCmd(args []string) {
+	fs := newFlagSet("clean")
+	dryRun := fs.Bool("dry-run", false, "Only list the unreferenced files")
+	purge := fs.Bool("purge", false, "Delete the files instead of moving them to the trash folder")
+	trash := fs.String("trash", "", "Trash folder (default anki-tts-trash in the profile folder)")
+	parseFlags(fs, args)
+
+	resolveCollectionDir()
+	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	if *trash == "" {
+		*trash = path.Join(params.CollectionDir, "anki-tts-trash")
+	}
+
+	db, collection := openDB()
+	defer db.Close()
+
+	referenced, templates := referencedMedia(db, collection)
+
+	journaled, err := ankitts.JournaledFiles(params.CollectionDir)
+	panicIfErrf(err, "loading journals")
+
+	files, err := ioutil.ReadDir(mediaDir)
+	panicIfErrf(err, "listing %s", mediaDir)
+	res := cleanResult{DryRun: *dryRun, Purged: *purge, Files: []string{}}
+	if !*purge {
+		res.Trash = *trash
+	}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || referenced[name] || strings.Contains(templates, name) {
+			continue
+		}
+		isTTS := journaled[path.Join(mediaDir, name)] || hashedMediaRegexp.MatchString(name) || legacyMediaRegexp.MatchString(name)
+		if !isTTS {
+			continue
+		}
+		res.Files = append(res.Files, name)
+		res.Bytes += f.Size()
+	}
+	sort.Strings(res.Files)
+
+	if !*dryRun && len(res.Files) > 0 {
+		mediaDB = openMediaDB()
+		defer mediaDB.Close()
+		if *purge {
+			for _, name := range res.Files {
+				panicIfErrf(os.Remove(path.Join(mediaDir, name)), "removing %s", name)
+				panicIfErrf(mediaDB.Deleted(path.Join(mediaDir, name)), "unregistering %s", name)
+				logf("Removed %s\n", name)
+			}
+		} else {
+			panicIfErrf(os.MkdirAll(*trash, 0755), "creating %s", *trash)
+			journal = ankitts.NewJournal(params.CollectionDir)
+			for _, name := range res.Files {
+				from, to := path.Join(mediaDir, name), path.Join(*trash, name)
+				panicIfErrf(journal.AddRename(from, to), "journaling %s", name)
+				panicIfErrf(os.Rename(from, to), "moving %s", name)
+				panicIfErrf(mediaDB.Deleted(from), "unregistering %s", name)
+				logf("Moved %s to %s\n", name, *trash)
+			}
+			res.RunID = journal.RunID
+		}
+	}
+
+	printResult(res, func() {
+		for _, name := range res.Files {
+			fmt.Println(name)
+		}
+		switch {
+		case res.DryRun:
+			fmt.Printf("%d unreferenced files, %s\n", len(res.Files), formatBytes(res.Bytes))
+		case res.Purged:
+			fmt.Printf("Deleted %d files, %s reclaimed\n", len(res.Files), formatBytes(res.Bytes))
+		default:
+			fmt.Printf("Moved %d files (%s) to %s\n", len(res.Files), formatBytes(res.Bytes), res.Trash)
+		}
+		if res.RunID != "" {
+			fmt.Printf("Run %s, restore with: anki-tts undo %s\n", res.RunID, res.RunID)
+		}
+	})
+}
+
+// referencedMedia returns the files used by [sound:] tags in the notes, and the templates and CSS of all note types,
+// which can use files too.
+func referencedMedia(db *anki.DB, collection *anki.Collection) (map[string]bool, string) {
+	referenced := map[string]bool{}
+	rows, err := db.Query("select flds from notes where flds like '%[sound:%'")
+	panicIfErrf(err, "loading notes")
+	for rows.Next() {
+		var flds string
+		panicIfErrf(rows.Scan(&flds), "loading notes")
+		for _, groups := range soundRegexp.FindAllStringSubmatch(flds, -1) {
+			referenced[groups[1]] = true
+		}
+	}
+	panicIfErrf(rows.Err(), "loading notes")
+	rows.Close()
+	var templates strings.Builder
+	for _, model := range collection.Models {
+		templates.WriteString(model.CSS)
+		for _, tmpl := range model.Templates {
+			templates.WriteString(tmpl.QuestionFormat)
+			templates.WriteString(tmpl.AnswerFormat)
+		}
+	}
+	return referenced, templates.String()
+}
+
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(bytes)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
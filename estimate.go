@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+)
+
+type jobEstimate struct {
+	Job    string `json:"job,omitempty"`
+	Engine string `json:"engine"`
+	Notes  int    `json:"notes"`
+	Files  int    `json:"files"`
+	Chars  int    `json:"chars"`
+}
+
+type engineEstimate struct {
+	Engine     string `json:"engine"`
+	Chars      int    `json:"chars"`
+	MonthUsed  int    `json:"month_used"`
+	MonthlyCap int    `json:"monthly_cap,omitempty"`
+}
+
+type estimateResult struct {
+	Jobs    []jobEstimate    `json:"jobs"`
+	Engines []engineEstimate `json:"engines"`
+}
+
+// estimateCmd estimates the notes selected with flags (like generate), one job, or (without selection flags) all
+// jobs from the config file (like run).
+func estimateCmd(args []string) {
+	fs := newFlagSet("estimate")
+	generateFlags(fs)
+	parseFlags(fs, args)
+
+	jobs := []ankitts.Params{{}}
+	if fs.NArg() > 0 {
+		job, err := config.Job(fs.Arg(0))
+		exitIfErr(err)
+		jobs = []ankitts.Params{job}
+	} else if flagParams.DeckName == "" && flagParams.CardType == "" && flagParams.Query == "" && len(config.Jobs) > 0 {
+		jobs = config.Jobs
+	}
+
+	var res estimateResult
+	engines := map[string]int{}
+	for _, job := range jobs {
+		useParams(jobParams(job), fs, "t", "d", "s", "l")
+		est := estimate()
+		est.Job = job.Name
+		res.Jobs = append(res.Jobs, est)
+		engines[est.Engine] += est.Chars
+	}
+
+	ledger := loadLedger()
+	for _, engine := range sortedKeys(engines) {
+		res.Engines = append(res.Engines, engineEstimate{
+			Engine:     engine,
+			Chars:      engines[engine],
+			MonthUsed:  ledger.MonthTotal(time.Now(), engine),
+			MonthlyCap: config.MonthlyCaps[engine],
+		})
+	}
+
+	printResult(res, func() {
+		for _, j := range res.Jobs {
+			if j.Job != "" {
+				fmt.Printf("Job %s\n", j.Job)
+			}
+			fmt.Printf("%d notes to update, %d files, %d characters (%s)\n", j.Notes, j.Files, j.Chars, j.Engine)
+		}
+		for _, e := range res.Engines {
+			fmt.Printf("%s: %d characters, %d used this month", e.Engine, e.Chars, e.MonthUsed)
+			if e.MonthlyCap > 0 {
+				fmt.Printf(", monthly cap %d", e.MonthlyCap)
+				if e.MonthUsed+e.Chars > e.MonthlyCap {
+					fmt.Printf(" (would be exceeded)")
+				}
+			}
+			fmt.Println()
+		}
+	})
+}
+
+// estimate counts the characters (after text preparation) of the audio files generate would create.
+func estimate() jobEstimate {
+	resolveCollectionDir()
+	db, collection := openDB()
+	defer db.Close()
+
+	namer = ankitts.NewMediaNamer(params)
+	res := jobEstimate{Engine: params.Engine}
+	// Files with the same text in several notes are synthesized once
+	counted := map[string]bool{}
+	forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+		if plan := planNote(sel); plan != nil {
+			pending := plan.pending(counted)
+			res.Notes++
+			res.Files += len(pending)
+			res.Chars += chars(pending)
+		}
+	})
+	return res
+}
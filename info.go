@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+	"github.com/tkrajina/anki"
+	"github.com/tkrajina/bingtts"
+)
+
+type profilesResult struct {
+	BaseDir  string   `json:"base_dir"`
+	Profiles []string `json:"profiles"`
+}
+
+func profilesCmd(args []string) {
+	fs := newFlagSet("profiles")
+	parseFlags(fs, args)
+
+	baseDir, err := ankitts.FindAnkiBaseDir()
+	exitIfErr(err)
+	profiles, err := ankitts.ListProfiles(baseDir)
+	panicIfErrf(err, "listing profiles")
+
+	res := profilesResult{BaseDir: baseDir, Profiles: append([]string{}, profiles...)}
+	printResult(res, func() {
+		for _, p := range res.Profiles {
+			fmt.Println(path.Join(baseDir, p))
+		}
+	})
+}
+
+type deckInfo struct {
+	ID       anki.ID `json:"id"`
+	Name     string  `json:"name"`
+	Filtered bool    `json:"filtered"`
+	Cards    int     `json:"cards"`
+}
+
+func decksCmd(args []string) {
+	fs := newFlagSet("decks")
+	parseFlags(fs, args)
+
+	db, collection := openDB()
+	defer db.Close()
+
+	cardCounts := countBy(db, "select did, count(*) from cards group by did")
+
+	res := []deckInfo{}
+	for _, deck := range collection.Decks {
+		res = append(res, deckInfo{ID: deck.ID, Name: deck.Name, Filtered: bool(deck.Dynamic), Cards: cardCounts[deck.ID]})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	printResult(res, func() {
+		for _, d := range res {
+			filtered := ""
+			if d.Filtered {
+				filtered = " (filtered)"
+			}
+			fmt.Printf("%s%s: %d cards\n", d.Name, filtered, d.Cards)
+		}
+	})
+}
+
+type modelInfo struct {
+	ID     anki.ID  `json:"id"`
+	Name   string   `json:"name"`
+	Cloze  bool     `json:"cloze"`
+	Fields []string `json:"fields"`
+	Notes  int      `json:"notes"`
+}
+
+func modelsCmd(args []string) {
+	fs := newFlagSet("models")
+	parseFlags(fs, args)
+
+	db, collection := openDB()
+	defer db.Close()
+
+	noteCounts := countBy(db, "select mid, count(*) from notes group by mid")
+
+	res := []modelInfo{}
+	for _, model := range collection.Models {
+		res = append(res, modelInfo{
+			ID:     model.ID,
+			Name:   model.Name,
+			Cloze:  model.Type == anki.ModelTypeCloze,
+			Fields: fieldNames(*model),
+			Notes:  noteCounts[model.ID],
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	printResult(res, func() {
+		for _, m := range res {
+			fmt.Printf("%s: %d notes, fields: %s\n", m.Name, m.Notes, strings.Join(m.Fields, ", "))
+		}
+	})
+}
+
+func fieldsCmd(args []string) {
+	fs := newFlagSet("fields")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: anki-tts fields [-c <collection> | -profile <name>] <model>")
+		os.Exit(1)
+	}
+
+	db, collection := openDB()
+	defer db.Close()
+
+	for _, model := range collection.Models {
+		if model.Name == fs.Arg(0) {
+			res := fieldNames(*model)
+			printResult(res, func() {
+				for _, f := range res {
+					fmt.Println(f)
+				}
+			})
+			return
+		}
+	}
+	fmt.Printf("Model %s not found\n", fs.Arg(0))
+	os.Exit(1)
+}
+
+func voicesCmd(args []string) {
+	fs := newFlagSet("voices")
+	parseFlags(fs, args)
+
+	locale := strings.ToLower(fs.Arg(0))
+
+	res := []bingtts.Voice{}
+	for _, voices := range bingtts.GetVoices() {
+		for _, voice := range voices {
+			if strings.HasPrefix(strings.ToLower(voice.Locale), locale) {
+				res = append(res, voice)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Locale != res[j].Locale {
+			return res[i].Locale < res[j].Locale
+		}
+		return res[i].VoiceName < res[j].VoiceName
+	})
+
+	printResult(res, func() {
+		for _, v := range res {
+			fmt.Printf("%s %s %s\n", v.Locale, v.Gender, v.VoiceName)
+		}
+	})
+}
+
+type statsResult struct {
+	Notes         int `json:"notes"`
+	Fields        int `json:"fields"`
+	FieldsWithTTS int `json:"fields_with_audio"`
+	FieldsNoTTS   int `json:"fields_without_audio"`
+	FieldsEmpty   int `json:"fields_empty"`
+	MissingFiles  int `json:"missing_files"`
+}
+
+var soundRegexp = regexp.MustCompile(`\[sound:(.*?)\]`)
+
+func statsCmd(args []string) {
+	fs := newFlagSet("stats")
+	selectionFlags(fs)
+	targetFlag(fs)
+	parseFlags(fs, args, "t", "d", "s")
+
+	db, collection := openDB()
+	defer db.Close()
+
+	var res statsResult
+	forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+		res.Notes++
+		for _, f := range checkSpeechFields(sel.Note, *sel.Model) {
+			res.Fields++
+			switch {
+			case f.empty:
+				res.FieldsEmpty++
+			case len(f.sounds) == 0:
+				res.FieldsNoTTS++
+			default:
+				res.FieldsWithTTS++
+				res.MissingFiles += len(f.missing)
+			}
+		}
+	})
+
+	printResult(res, func() {
+		fmt.Printf("Notes:                %d\n", res.Notes)
+		fmt.Printf("Fields:               %d\n", res.Fields)
+		fmt.Printf("Fields with audio:    %d\n", res.FieldsWithTTS)
+		fmt.Printf("Fields without audio: %d\n", res.FieldsNoTTS)
+		fmt.Printf("Empty fields:         %d\n", res.FieldsEmpty)
+		fmt.Printf("Missing audio files:  %d\n", res.MissingFiles)
+	})
+}
+
+type verifyProblem struct {
+	NoteID  anki.ID `json:"note_id"`
+	Field   string  `json:"field"`
+	Problem string  `json:"problem"`
+}
+
+func verifyCmd(args []string) {
+	fs := newFlagSet("verify")
+	selectionFlags(fs)
+	targetFlag(fs)
+	parseFlags(fs, args, "t", "d", "s")
+
+	db, collection := openDB()
+	defer db.Close()
+
+	res := []verifyProblem{}
+	forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+		for _, f := range checkSpeechFields(sel.Note, *sel.Model) {
+			if f.empty {
+				continue
+			}
+			if len(f.sounds) == 0 {
+				res = append(res, verifyProblem{NoteID: sel.Note.ID, Field: f.name, Problem: "no audio"})
+			}
+			for _, missing := range f.missing {
+				res = append(res, verifyProblem{NoteID: sel.Note.ID, Field: f.name, Problem: "missing file " + missing})
+			}
+		}
+	})
+
+	printResult(res, func() {
+		for _, p := range res {
+			fmt.Printf("note %d, field %s: %s\n", p.NoteID, p.Field, p.Problem)
+		}
+		if len(res) == 0 {
+			fmt.Println("OK")
+		}
+	})
+	if len(res) > 0 {
+		os.Exit(1)
+	}
+}
+
+type speechFieldCheck struct {
+	name            string
+	empty           bool
+	sounds, missing []string
+}
+
+// checkSpeechFields checks the audio of the note's speech fields. If the audio goes to another field (see audioField)
+// that field is checked instead, it's empty if all speech fields are. Cloze notes without a field for the audio get
+// none, they aren't checked.
+func checkSpeechFields(note anki.Note, model anki.Model) []speechFieldCheck {
+	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	targetField := audioField(model)
+	if targetField == "" && model.Type == anki.ModelTypeCloze {
+		return nil
+	}
+
+	var res []speechFieldCheck
+	var target *speechFieldCheck
+	for n := range model.Fields {
+		fieldName := model.Fields[n].Name
+		if n >= len(note.FieldValues) {
+			continue
+		}
+		value := note.FieldValues[n]
+		if fieldName == targetField {
+			target = &speechFieldCheck{name: fieldName}
+			target.sounds, target.missing = checkSounds(mediaDir, value)
+		}
+		if _, found := speechColumns[fieldName]; !found {
+			continue
+		}
+		check := speechFieldCheck{name: fieldName}
+		check.empty = strings.TrimSpace(soundRegexp.ReplaceAllString(value, "")) == ""
+		check.sounds, check.missing = checkSounds(mediaDir, value)
+		res = append(res, check)
+	}
+	if targetField == "" {
+		return res
+	}
+	if target == nil {
+		return nil
+	}
+	target.empty = true
+	for _, check := range res {
+		target.empty = target.empty && check.empty
+	}
+	return []speechFieldCheck{*target}
+}
+
+// checkSounds returns the sound files of the field and the ones which don't exist in the media directory.
+func checkSounds(mediaDir, value string) (sounds, missing []string) {
+	for _, match := range soundRegexp.FindAllStringSubmatch(value, -1) {
+		sounds = append(sounds, match[1])
+		if _, err := os.Stat(path.Join(mediaDir, match[1])); os.IsNotExist(err) {
+			missing = append(missing, match[1])
+		}
+	}
+	return sounds, missing
+}
+
+func fieldNames(model anki.Model) []string {
+	res := []string{}
+	for _, f := range model.Fields {
+		res = append(res, f.Name)
+	}
+	return res
+}
+
+func countBy(db *anki.DB, query string) map[anki.ID]int {
+	res := map[anki.ID]int{}
+	rows, err := db.Query(query)
+	panicIfErrf(err, "querying %s", query)
+	defer rows.Close()
+	for rows.Next() {
+		var id anki.ID
+		var count int
+		panicIfErrf(rows.Scan(&id, &count), "scanning %s", query)
+		res[id] = count
+	}
+	return res
+}
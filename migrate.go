@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+	"github.com/tkrajina/anki"
+)
+
+// Files named by older versions: <locale>-<text with non-letters replaced by _>.mp3
+var legacyMediaRegexp = regexp.MustCompile(`^([a-z]{2,3}-[A-Za-z]{2,4})-(.+)\.mp3$`)
+
+type migrateResult struct {
+	RunID        string            `json:"run_id,omitempty"`
+	DryRun       bool              `json:"dry_run"`
+	Renamed      map[string]string `json:"renamed"`
+	UpdatedNotes []anki.ID         `json:"updated_notes"`
+	// Legacy files referenced by notes, but without a field with the text they were created from. If other notes resolve
+	// them they're copied to the new names instead of renamed, so that these references stay valid.
+	Unresolved []string `json:"unresolved"`
+}
+
+type migratedNote struct {
+	id       anki.ID
+	previous []string
+	current  []string
+}
+
+func migrateMediaCmd(args []string) {
+	fs := newFlagSet("migrate-media")
+	fs.StringVar(&flagParams.Voice, "voice", "", "Voice the files were created with (part of the new name's hash)")
+	fs.StringVar(&flagParams.Engine, "engine", "", "TTS engine the files were created with (default bing)")
+	fs.StringVar(&flagParams.Naming, "naming", "", "Media filename template (default {locale}-{slug}-{hash})")
+	fs.BoolVar(&flagParams.Transliterate, "transliterate", false, "ASCII only media filenames")
+	dryRun := fs.Bool("dry-run", false, "Only show what would be renamed")
+	parseFlags(fs, args)
+
+	resolveCollectionDir()
+	mediaDir := path.Join(params.CollectionDir, "collection.media")
+	if !*dryRun {
+		backup()
+	}
+	db, collection := openDB()
+	defer db.Close()
+
+	namer = ankitts.NewMediaNamer(params)
+	jobs := noteJobs(db, collection)
+	res := migrateResult{DryRun: *dryRun, Renamed: map[string]string{}, UpdatedNotes: []anki.ID{}, Unresolved: []string{}}
+	// Legacy name -> new names (the same legacy name can be shared by texts differing only in punctuation)
+	newNames := map[string][]string{}
+	unresolved := map[string]bool{}
+	var notes []migratedNote
+
+	rows, err := db.Query("select id, flds from notes where flds like '%[sound:%' order by id")
+	panicIfErrf(err, "loading notes")
+	for rows.Next() {
+		var id anki.ID
+		var flds string
+		panicIfErrf(rows.Scan(&id, &flds), "loading notes")
+		fields := strings.Split(flds, anki.FieldValuesDelimiter)
+		current := append([]string(nil), fields...)
+		for n := range current {
+			current[n] = soundRegexp.ReplaceAllStringFunc(current[n], func(sound string) string {
+				name := soundRegexp.FindStringSubmatch(sound)[1]
+				p, found := jobs[id]
+				if !found {
+					p = params
+				}
+				newName := migratedName(p, name, fields, mediaDir)
+				if newName == "" {
+					if legacyMediaRegexp.MatchString(name) && !migratedMediaRegexp.MatchString(name) {
+						unresolved[name] = true
+					}
+					return sound
+				}
+				if !contains(newNames[name], newName) {
+					newNames[name] = append(newNames[name], newName)
+				}
+				return fmt.Sprintf("[sound:%s]", newName)
+			})
+		}
+		if strings.Join(current, anki.FieldValuesDelimiter) != flds {
+			notes = append(notes, migratedNote{id: id, previous: fields, current: current})
+		}
+	}
+	panicIfErrf(rows.Err(), "loading notes")
+	rows.Close()
+
+	for name := range unresolved {
+		res.Unresolved = append(res.Unresolved, name)
+	}
+	sort.Strings(res.Unresolved)
+	for name, names := range newNames {
+		res.Renamed[name] = strings.Join(names, ", ")
+	}
+	for _, n := range notes {
+		res.UpdatedNotes = append(res.UpdatedNotes, n.id)
+	}
+
+	if !*dryRun && len(notes) > 0 {
+		journal = ankitts.NewJournal(params.CollectionDir)
+		mediaDB = openMediaDB()
+		defer mediaDB.Close()
+		// The notes are committed first, if a file can't be renamed the journal has both and the run can be reverted
+		migrateNotes(db, notes)
+		migrateFiles(mediaDir, newNames, unresolved)
+		res.RunID = journal.RunID
+	}
+
+	printResult(res, func() {
+		var names []string
+		for name := range res.Renamed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s -> %s\n", name, res.Renamed[name])
+		}
+		for _, name := range res.Unresolved {
+			if res.Renamed[name] != "" {
+				fmt.Printf("Copied instead of renamed (some notes have no field with its text): %s\n", name)
+			} else {
+				fmt.Printf("Not migrated (no field with its text): %s\n", name)
+			}
+		}
+		verb := "Renamed"
+		if res.DryRun {
+			verb = "Would rename"
+		}
+		fmt.Printf("%s %d files in %d notes\n", verb, len(res.Renamed), len(res.UpdatedNotes))
+		if res.RunID != "" {
+			fmt.Printf("Run %s, revert with: anki-tts undo %s\n", res.RunID, res.RunID)
+		}
+	})
+}
+
+// Names with the hash of the default naming scheme
+var migratedMediaRegexp = regexp.MustCompile(`-[0-9a-f]{12}(-\d+)?\.\w+$`)
+
+// noteJobs returns the params of the first job from the config file selecting each note of the collection (in any card
+// state), so that its files are renamed with the job's voice, naming and text preparation. Other notes are migrated
+// with params.
+func noteJobs(db *anki.DB, collection *anki.Collection) map[anki.ID]ankitts.Params {
+	collectionParams := params
+	defer func() { params = collectionParams }()
+
+	res := map[anki.ID]ankitts.Params{}
+	for _, job := range config.Jobs {
+		params = jobParams(job)
+		if requiredParams["t"](params) == "" || requiredParams["d"](params) == "" {
+			continue
+		}
+		resolveCollectionDir()
+		if params.CollectionDir != collectionParams.CollectionDir {
+			continue
+		}
+		p := params
+		params.CardStates, params.DueWithin = "all", ""
+		forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+			if _, found := res[sel.Note.ID]; !found {
+				res[sel.Note.ID] = p
+			}
+		})
+	}
+	return res
+}
+
+// migratedName finds the field the legacy file was created from and returns the new name with the params of the
+// note's job, or an empty string if the name isn't a legacy name, the file doesn't exist or the text can't be found.
+func migratedName(p ankitts.Params, name string, fields []string, mediaDir string) string {
+	groups := legacyMediaRegexp.FindStringSubmatch(name)
+	if groups == nil {
+		return ""
+	}
+	if _, err := os.Stat(path.Join(mediaDir, name)); err != nil {
+		return ""
+	}
+	locale, slug := groups[1], groups[2]
+	for _, field := range fields {
+		// The same text as in planNote
+		text := regexp.MustCompile(`\[.*?\]`).ReplaceAllString(strings.TrimSpace(field), "")
+		if text == "" || ankitts.PrepareDestfilename(text) != slug {
+			continue
+		}
+		p.LanguageLocale = locale
+		p.Format = "mp3"
+		localePipeline, err := newPipeline(p, locale)
+		exitIfErr(err)
+		newName := namer.Name(p, text, localePipeline.Apply(text))
+		if newName == name {
+			return ""
+		}
+		return newName
+	}
+	return ""
+}
+
+// migrateFiles renames every legacy file to its first new name and copies it to the others. Files still referenced by
+// unresolved notes are only copied. Files already existing under the new name are left as they are.
+func migrateFiles(mediaDir string, newNames map[string][]string, unresolved map[string]bool) {
+	var names []string
+	for name := range newNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		from := path.Join(mediaDir, name)
+		var copies []string
+		renamed := ""
+		for _, newName := range newNames[name] {
+			to := path.Join(mediaDir, newName)
+			if _, err := os.Stat(to); err == nil {
+				continue
+			}
+			if renamed == "" && !unresolved[name] {
+				renamed = to
+				continue
+			}
+			copies = append(copies, to)
+		}
+		for _, to := range copies {
+			panicIfErrf(journal.AddFile(to), "journaling %s", to)
+			byts, err := ioutil.ReadFile(from)
+			panicIfErrf(err, "reading %s", from)
+			panicIfErrf(ioutil.WriteFile(to, byts, 0644), "copying %s", from)
+			panicIfErrf(mediaDB.Added(to), "registering %s", to)
+			logf("Copied %s to %s\n", name, path.Base(to))
+		}
+		if renamed == "" {
+			continue
+		}
+		panicIfErrf(journal.AddRename(from, renamed), "journaling %s", from)
+		panicIfErrf(os.Rename(from, renamed), "renaming %s", from)
+		panicIfErrf(mediaDB.Deleted(from), "unregistering %s", from)
+		panicIfErrf(mediaDB.Added(renamed), "registering %s", renamed)
+		logf("Renamed %s to %s\n", name, path.Base(renamed))
+	}
+}
+
+// migrateNotes updates all notes in one transaction.
+func migrateNotes(db *anki.DB, notes []migratedNote) {
+	modified := time.Now().Unix()
+	tx, err := db.Begin()
+	panicIfErrf(err, "starting transaction")
+	for _, n := range notes {
+		_, err := tx.Exec("update notes set flds=?, mod=?, usn=-1 where id=?", strings.Join(n.current, anki.FieldValuesDelimiter), modified, n.id)
+		if err != nil {
+			tx.Rollback()
+			panicIfErrf(err, "updating %d", n.id)
+		}
+	}
+	for _, n := range notes {
+		panicIfErrf(journal.AddNote(n.id, n.previous, n.current, modified), "journaling %d", n.id)
+	}
+	panicIfErrf(tx.Commit(), "committing")
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+	"github.com/tkrajina/anki"
+)
+
+type textPreview struct {
+	NoteID   anki.ID              `json:"note_id,omitempty"`
+	Field    string               `json:"field,omitempty"`
+	Text     string               `json:"text"`
+	Steps    []ankitts.StepResult `json:"steps"`
+	Override string               `json:"override,omitempty"`
+	Spoken   string               `json:"spoken"`
+	// Texts in other languages, see prepareSpeech
+	Segments         []ankitts.Segment        `json:"segments,omitempty"`
+	ScriptMismatches []ankitts.ScriptMismatch `json:"script_mismatches,omitempty"`
+}
+
+// previewTextCmd shows the text after every step of the text preparation pipeline, for texts given as arguments or
+// for the speech fields of the selected notes.
+func previewTextCmd(args []string) {
+	fs := newFlagSet("preview-text")
+	generateFlags(fs)
+	jobName := fs.String("job", "", "Use the text preparation of this job from the config file")
+	limit := fs.Int("n", 5, "Maximum number of notes to preview")
+	parseFlags(fs, args)
+	if *jobName != "" {
+		job, err := config.Job(*jobName)
+		exitIfErr(err)
+		useParams(jobParams(job), fs)
+	}
+
+	var res []textPreview
+	if fs.NArg() > 0 {
+		for _, text := range fs.Args() {
+			res = append(res, previewText(text))
+		}
+	} else {
+		useParams(params, fs, "t", "d", "s")
+		resolveCollectionDir()
+		db, collection := openDB()
+		defer db.Close()
+		forEachSelectedNote(db, collection, func(sel ankitts.SelectedNote) {
+			if len(res) >= *limit {
+				return
+			}
+			for n, field := range sel.Model.Fields {
+				text := strings.TrimSpace(sel.Note.FieldValues[n])
+				if !speechColumns[field.Name] || text == "" {
+					continue
+				}
+				preview := previewText(ankitts.RevealClozes(speechSource(*sel.Model, sel.Note, text)))
+				preview.NoteID, preview.Field = sel.Note.ID, field.Name
+				if override := ankitts.OverrideText(fieldValue(*sel.Model, sel.Note, params.OverrideField)); override != "" {
+					preview.Override, preview.Spoken, preview.Segments, preview.ScriptMismatches = override, override, nil, nil
+				}
+				res = append(res, preview)
+			}
+		})
+	}
+
+	printResult(res, func() {
+		for _, preview := range res {
+			if preview.NoteID != 0 {
+				fmt.Printf("Note %d, %s\n", preview.NoteID, preview.Field)
+			}
+			fmt.Printf("  %-40s %q\n", "input", preview.Text)
+			for _, step := range preview.Steps {
+				fmt.Printf("  %-40s %q\n", step.Step, step.Text)
+			}
+			for _, segment := range preview.Segments {
+				fmt.Printf("  %-40s %q\n", strings.TrimSuffix("segment "+segment.Locale+" "+segment.Voice, " "), segment.Text)
+			}
+			for _, m := range preview.ScriptMismatches {
+				fmt.Printf("  %-40s %q\n", "script mismatch "+m.Locale+": "+strings.Join(m.Scripts, ", "), m.Text)
+			}
+			if preview.Override != "" {
+				fmt.Printf("  %-40s %q\n", "override (spoken instead)", preview.Override)
+			}
+			fmt.Println()
+		}
+	})
+}
+
+func previewText(text string) textPreview {
+	steps := pipeline.Trace(withoutLanguageMarkers(text))
+	spoken, segments, mismatches := prepareSpeech(text)
+	return textPreview{Text: text, Steps: steps, Spoken: spoken, Segments: segments, ScriptMismatches: mismatches}
+}
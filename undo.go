@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"bitbucket.org/puzz/anki-tts/ankitts"
+	"github.com/tkrajina/anki"
+)
+
+type undoResult struct {
+	RunID         string    `json:"run_id"`
+	RevertedNotes []anki.ID `json:"reverted_notes"`
+	RemovedFiles  []string  `json:"removed_files"`
+	// Files created by the run but used by other notes since
+	KeptFiles    []string `json:"kept_files"`
+	RenamedFiles []string `json:"renamed_files"`
+}
+
+func undoCmd(args []string) {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.BoolVar(&jsonOutput, "json", false, "JSON output")
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: anki-tts undo <run-id>")
+		os.Exit(1)
+	}
+	runID := fs.Arg(0)
+
+	j, err := ankitts.LoadJournal(runID)
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("no journal of run %s", runID)
+	}
+	exitIfErr(err)
+
+	params.CollectionDir = j.CollectionDir
+	backup()
+	mediaDB = openMediaDB()
+	defer mediaDB.Close()
+
+	collectionsDb := path.Join(j.CollectionDir, "collection.anki2")
+	db, err := anki.OpenOriginalDB(collectionsDb)
+	panicIfErrf(err, "opening db %s", collectionsDb)
+	defer db.Close()
+
+	res := undoResult{RunID: runID, RevertedNotes: []anki.ID{}, RemovedFiles: []string{}, KeptFiles: []string{}, RenamedFiles: []string{}}
+
+	var modifiedAfter []string
+	for _, n := range j.Notes {
+		var flds string
+		var mod int64
+		err := db.QueryRow("select flds, mod from notes where id=?", n.NoteID).Scan(&flds, &mod)
+		if err != nil {
+			modifiedAfter = append(modifiedAfter, fmt.Sprintf("%d (%s)", n.NoteID, err.Error()))
+			continue
+		}
+		if flds != strings.Join(n.Current, anki.FieldValuesDelimiter) || mod != n.Modified {
+			modifiedAfter = append(modifiedAfter, fmt.Sprintf("%d", n.NoteID))
+		}
+	}
+	// A rename is journaled before the file is renamed, if the run stopped there only the old file exists
+	for _, r := range j.Renames {
+		if _, err := os.Stat(r.To); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(r.From); err == nil {
+			modifiedAfter = append(modifiedAfter, fmt.Sprintf("%s (exists again)", path.Base(r.From)))
+		}
+	}
+	if len(modifiedAfter) > 0 {
+		fmt.Fprintf(os.Stderr, "Notes or files modified after run %s, refusing to undo: %s\n", runID, strings.Join(modifiedAfter, ", "))
+		os.Exit(1)
+	}
+
+	tx, err := db.Begin()
+	panicIfErrf(err, "starting transaction")
+	for _, n := range j.Notes {
+		fieldsJoined := strings.Join(n.Previous, anki.FieldValuesDelimiter)
+		_, err := tx.Exec("update notes set flds=?, mod=?, usn=-1 where id=?", fieldsJoined, time.Now().Unix(), n.NoteID)
+		if err != nil {
+			tx.Rollback()
+			panicIfErrf(err, "reverting %d", n.NoteID)
+		}
+		logf("Reverted %d to %s\n", n.NoteID, fieldsJoined)
+		res.RevertedNotes = append(res.RevertedNotes, n.NoteID)
+	}
+	panicIfErrf(tx.Commit(), "committing")
+
+	// A later run can reuse a file of this run for another note with the same text (the hashed name is the same), without
+	// journaling it
+	collection, err := db.Collection()
+	panicIfErrf(err, "getting collection")
+	referenced, templates := referencedMedia(db, collection)
+	for _, fn := range j.Files {
+		if name := path.Base(fn); referenced[name] || strings.Contains(templates, name) {
+			logf("Kept %s, still used\n", fn)
+			res.KeptFiles = append(res.KeptFiles, fn)
+			continue
+		}
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			panicIfErrf(err, "removing %s", fn)
+		}
+		panicIfErrf(mediaDB.Deleted(fn), "unregistering %s", fn)
+		logf("Removed %s\n", fn)
+		res.RemovedFiles = append(res.RemovedFiles, fn)
+	}
+
+	for n := len(j.Renames) - 1; n >= 0; n-- {
+		r := j.Renames[n]
+		if _, err := os.Stat(r.To); os.IsNotExist(err) {
+			logf("%s doesn't exist, not renaming back\n", r.To)
+			continue
+		}
+		panicIfErrf(os.Rename(r.To, r.From), "renaming %s", r.To)
+		panicIfErrf(mediaDB.Deleted(r.To), "unregistering %s", r.To)
+		panicIfErrf(mediaDB.Added(r.From), "registering %s", r.From)
+		logf("Renamed %s back to %s\n", r.To, r.From)
+		res.RenamedFiles = append(res.RenamedFiles, r.From)
+	}
+
+	panicIfErrf(j.MarkUndone(), "marking %s as undone", runID)
+	printResult(res, func() {
+		fmt.Printf("Run %s reverted\n", runID)
+		for _, fn := range res.KeptFiles {
+			fmt.Printf("Kept %s, still used by a note or template\n", path.Base(fn))
+		}
+	})
+}